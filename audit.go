@@ -0,0 +1,331 @@
+package proxy
+
+import (
+	"bufio"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// AuditAction specifies audited operation.
+type AuditAction string
+
+// AuditAction values.
+const (
+	AuditCreateTask AuditAction = "create_task"
+	AuditKillTask   AuditAction = "kill_task"
+	AuditPauseTask  AuditAction = "pause_task"
+	AuditResumeTask AuditAction = "resume_task"
+	AuditRetryTask  AuditAction = "retry_task"
+	AuditShutdown   AuditAction = "shutdown"
+)
+
+// AuditOutcome specifies outcome of audited operation.
+type AuditOutcome string
+
+// AuditOutcome values.
+const (
+	AuditSuccess AuditOutcome = "success"
+	AuditFailure AuditOutcome = "failure"
+)
+
+// AuditEntry is a record of audit log, entries are chained by HMACs so that
+// modification, removal or reordering of entries is detectable by holders of
+// the key. Removal of trailing entries is detectable only by comparing the last
+// sequence number and hash with a previously recorded value.
+type AuditEntry struct {
+	Seq    uint64      `json:"seq"`
+	Time   time.Time   `json:"time"`
+	Action AuditAction `json:"action"`
+	// Actor is client ID of the caller, empty if authentication is
+	// disabled.
+	Actor      string `json:"actor,omitempty"`
+	AuthMethod string `json:"auth_method,omitempty"`
+	Role       string `json:"role,omitempty"`
+	SourceIP   string `json:"source_ip,omitempty"`
+	RequestID  string `json:"request_id,omitempty"`
+	TaskID     TaskID `json:"task_id,omitempty"`
+	// ChildID is identifier of the task retrying the task.
+	ChildID TaskID `json:"child_id,omitempty"`
+	// Config is task configuration with payloads replaced by their SHA-256
+	// digests.
+	Config   *TaskConfig  `json:"config,omitempty"`
+	Reason   string       `json:"reason,omitempty"`
+	Addrs    []string     `json:"addrs,omitempty"`
+	Cascade  bool         `json:"cascade,omitempty"`
+	Statuses []Status     `json:"statuses,omitempty"`
+	Outcome  AuditOutcome `json:"outcome"`
+	Error    string       `json:"error,omitempty"`
+	// PrevHash is hash of the previous entry, Hash is hex encoded
+	// HMAC-SHA256 of the entry encoded with empty Hash.
+	PrevHash string `json:"prev_hash"`
+	Hash     string `json:"hash"`
+}
+
+// sum returns hash of the entry.
+func (e AuditEntry) sum(key []byte) (string, error) {
+	e.Hash = ""
+	b, err := json.Marshal(e)
+	if err != nil {
+		return "", err
+	}
+	h := hmac.New(sha256.New, key)
+	h.Write(b)
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// errEmptyAuditKey is returned when audit log key is empty.
+var errEmptyAuditKey = errors.New("empty audit key")
+
+// AuditFilter selects audit entries, zero values match all entries.
+type AuditFilter struct {
+	Since  time.Time
+	Until  time.Time
+	Actor  string
+	Action AuditAction
+}
+
+func (f AuditFilter) match(e *AuditEntry) bool {
+	if !f.Since.IsZero() && e.Time.Before(f.Since) {
+		return false
+	}
+	if !f.Until.IsZero() && !e.Time.Before(f.Until) {
+		return false
+	}
+	if f.Actor != "" && e.Actor != f.Actor {
+		return false
+	}
+	if f.Action != "" && e.Action != f.Action {
+		return false
+	}
+	return true
+}
+
+// AuditLog is an append-only, HMAC-chained JSONL file of audit entries.
+type AuditLog struct {
+	file string
+	key  []byte
+	f    *os.File
+	seq  uint64
+	hash string
+	// mu protects f, seq and hash
+	mu sync.Mutex
+}
+
+// OpenAuditLog opens or creates audit log file, existing file is verified
+// with key and new entries are chained to the last one. The key must be kept
+// outside the log.
+func OpenAuditLog(file string, key []byte) (*AuditLog, error) {
+	if len(key) == 0 {
+		return nil, errEmptyAuditKey
+	}
+
+	f, err := os.OpenFile(file, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, err
+	}
+
+	last, err := VerifyAudit(f, key)
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("failed to verify %s: %s", file, err)
+	}
+
+	l := &AuditLog{
+		file: file,
+		key:  key,
+		f:    f,
+	}
+	if last != nil {
+		l.seq = last.Seq
+		l.hash = last.Hash
+	}
+
+	return l, nil
+}
+
+// Record fills sequence number and hashes of e and appends it to the log.
+func (l *AuditLog) Record(e *AuditEntry) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	e.Seq = l.seq + 1
+	e.PrevHash = l.hash
+	h, err := e.sum(l.key)
+	if err != nil {
+		return err
+	}
+	e.Hash = h
+
+	b, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	if _, err := l.f.Write(append(b, '\n')); err != nil {
+		return err
+	}
+	if err := l.f.Sync(); err != nil {
+		return err
+	}
+
+	l.seq = e.Seq
+	l.hash = e.Hash
+
+	return nil
+}
+
+// Query returns entries matching filter in order.
+func (l *AuditLog) Query(filter AuditFilter) ([]AuditEntry, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	f, err := os.Open(l.file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	entries := []AuditEntry{}
+	s := bufio.NewScanner(f)
+	s.Buffer(nil, 2*MaximalBodySize)
+	for s.Scan() {
+		var e AuditEntry
+		if err := json.Unmarshal(s.Bytes(), &e); err != nil {
+			return nil, err
+		}
+		if filter.match(&e) {
+			entries = append(entries, e)
+		}
+	}
+
+	return entries, s.Err()
+}
+
+// Head returns sequence number and hash of the last entry, recording them
+// outside the log enables detection of removed trailing entries.
+func (l *AuditLog) Head() (uint64, string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.seq, l.hash
+}
+
+// Close closes the log file.
+func (l *AuditLog) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.f.Close()
+}
+
+// VerifyAudit reads audit log and checks sequence numbers and HMAC chain with
+// key, it returns the last entry or nil if the log is empty.
+func VerifyAudit(r io.Reader, key []byte) (*AuditEntry, error) {
+	if len(key) == 0 {
+		return nil, errEmptyAuditKey
+	}
+
+	var (
+		last *AuditEntry
+		line int
+	)
+
+	s := bufio.NewScanner(r)
+	s.Buffer(nil, 2*MaximalBodySize)
+	for s.Scan() {
+		line++
+
+		e := new(AuditEntry)
+		if err := json.Unmarshal(s.Bytes(), e); err != nil {
+			return nil, fmt.Errorf("line %d: %s", line, err)
+		}
+
+		var seq uint64
+		var prev string
+		if last != nil {
+			seq = last.Seq
+			prev = last.Hash
+		}
+		if e.Seq != seq+1 {
+			return nil, fmt.Errorf("line %d: expected seq %d got %d", line, seq+1, e.Seq)
+		}
+		if e.PrevHash != prev {
+			return nil, fmt.Errorf("line %d: broken hash chain", line)
+		}
+		h, err := e.sum(key)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %s", line, err)
+		}
+		if !hmac.Equal([]byte(e.Hash), []byte(h)) {
+			return nil, fmt.Errorf("line %d: hash mismatch", line)
+		}
+
+		last = e
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+
+	return last, nil
+}
+
+// redactConfig returns copy of config with payloads replaced by their
+// SHA-256 digests, payloads may carry credentials.
+func redactConfig(config *TaskConfig) *TaskConfig {
+	c := *config
+	c.Info = redact(c.Info)
+	c.CompensationInfo = redact(c.CompensationInfo)
+	c.PrepareInfo = redact(c.PrepareInfo)
+	c.AbortInfo = redact(c.AbortInfo)
+	if len(c.Steps) > 0 {
+		c.Steps = append([]Step(nil), c.Steps...)
+		for i := range c.Steps {
+			c.Steps[i].Info = redact(c.Steps[i].Info)
+		}
+	}
+	return &c
+}
+
+func redact(s string) string {
+	if s == "" {
+		return ""
+	}
+	h := sha256.Sum256([]byte(s))
+	return "sha256:" + hex.EncodeToString(h[:])
+}
+
+type sourceIPKey struct{}
+
+// WithSourceIP returns context with IP address of the caller.
+func WithSourceIP(ctx context.Context, ip string) context.Context {
+	return context.WithValue(ctx, sourceIPKey{}, ip)
+}
+
+// SourceIPFromContext returns IP address of the caller stored in ctx or empty
+// string.
+func SourceIPFromContext(ctx context.Context) string {
+	ip, _ := ctx.Value(sourceIPKey{}).(string)
+	return ip
+}
+
+// SourceIPMiddleware is a HTTP middleware that stores IP address of the
+// caller in request context.
+type SourceIPMiddleware struct {
+	Inner http.Handler
+}
+
+func (m SourceIPMiddleware) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		ip = r.RemoteAddr
+	}
+	m.Inner.ServeHTTP(w, r.WithContext(WithSourceIP(r.Context(), ip)))
+}
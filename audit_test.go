@@ -0,0 +1,213 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/golang/mock/gomock"
+)
+
+var testAuditKey = []byte("key")
+
+func tempAuditLog(t *testing.T) (string, func()) {
+	dir, err := ioutil.TempDir("", "proxy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return filepath.Join(dir, "audit.jsonl"), func() { os.RemoveAll(dir) }
+}
+
+func TestAuditLogVerify(t *testing.T) {
+	file, cleanup := tempAuditLog(t)
+	defer cleanup()
+
+	l, err := OpenAuditLog(file, testAuditKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, a := range []AuditAction{AuditCreateTask, AuditKillTask} {
+		if err := l.Record(&AuditEntry{Time: time.Now().UTC(), Action: a, Actor: "client", Outcome: AuditSuccess}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	l.Close()
+
+	// reopened log continues the chain
+	l, err = OpenAuditLog(file, testAuditKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Record(&AuditEntry{Time: time.Now().UTC(), Action: AuditShutdown, Outcome: AuditSuccess}); err != nil {
+		t.Fatal(err)
+	}
+	seq, hash := l.Head()
+	l.Close()
+
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	last, err := VerifyAudit(bytes.NewReader(b), testAuditKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if last.Seq != 3 || last.Action != AuditShutdown || last.Seq != seq || last.Hash != hash {
+		t.Fatal("wrong last entry", last)
+	}
+
+	lines := strings.SplitAfter(string(b), "\n")
+	table := []string{
+		strings.Replace(string(b), `"actor":"client"`, `"actor":"other"`, 1),
+		lines[0] + lines[2],
+		lines[1] + lines[2],
+	}
+	for i, v := range table {
+		if _, err := VerifyAudit(strings.NewReader(v), testAuditKey); err == nil {
+			t.Fatal(i, "expected error")
+		}
+	}
+
+	if _, err := VerifyAudit(bytes.NewReader(b), []byte("other")); err == nil {
+		t.Fatal("expected error")
+	}
+	if _, err := VerifyAudit(bytes.NewReader(b), nil); err == nil {
+		t.Fatal("expected error")
+	}
+
+	if err := ioutil.WriteFile(file, []byte(table[0]), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := OpenAuditLog(file, testAuditKey); err == nil {
+		t.Fatal("expected error")
+	}
+}
+
+func TestAuditService(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	file, cleanup := tempAuditLog(t)
+	defer cleanup()
+
+	l, err := OpenAuditLog(file, testAuditKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	m := NewMockService(ctrl)
+	m.EXPECT().CreateTask(gomock.Any(), gomock.Any()).Return(TaskID("test"), nil)
+	m.EXPECT().KillTask(gomock.Any(), TaskID("missing"), KillOptions{Reason: "bad"}).Return(nil, nil)
+	m.EXPECT().TaskStatus(gomock.Any(), TaskID("test")).Return(&TaskStatus{ID: "test"}, nil)
+
+	s := NewAuditService(m, l, log.NewNopLogger())
+
+	ctx := WithIdentity(context.Background(), &Identity{ClientID: "client", Method: "key", Role: "admin"})
+	ctx = WithRequestID(ctx, "req")
+	ctx = WithSourceIP(ctx, "10.0.0.1")
+
+	if _, err := s.CreateTask(ctx, &TaskConfig{Mode: Sequential, Info: "secret"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.KillTask(context.Background(), "missing", KillOptions{Reason: "bad"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.TaskStatus(ctx, "test"); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := l.Query(AuditFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 {
+		t.Fatal("wrong entries", entries)
+	}
+
+	e := entries[0]
+	if e.Action != AuditCreateTask || e.Actor != "client" || e.AuthMethod != "key" || e.Role != "admin" ||
+		e.RequestID != "req" || e.SourceIP != "10.0.0.1" || e.TaskID != "test" || e.Outcome != AuditSuccess {
+		t.Fatal("wrong entry", e)
+	}
+	if e.Config == nil || e.Config.Info != redact("secret") || e.Config.Mode != Sequential {
+		t.Fatal("wrong config", e.Config)
+	}
+
+	e = entries[1]
+	if e.Action != AuditKillTask || e.Reason != "bad" || e.Outcome != AuditFailure || e.Error != errTaskNotFound.Error() {
+		t.Fatal("wrong entry", e)
+	}
+
+	entries, err = l.Query(AuditFilter{Actor: "client"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Fatal("wrong entries", entries)
+	}
+
+	entries, err = l.Query(AuditFilter{Since: time.Now().Add(time.Minute)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 0 {
+		t.Fatal("wrong entries", entries)
+	}
+}
+
+func TestAuditServer(t *testing.T) {
+	file, cleanup := tempAuditLog(t)
+	defer cleanup()
+
+	l, err := OpenAuditLog(file, testAuditKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	now := time.Now().UTC()
+	for _, actor := range []string{"client:1", "client:2"} {
+		if err := l.Record(&AuditEntry{Time: now, Action: AuditCreateTask, Actor: actor, Outcome: AuditSuccess}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	s := NewAuditServer(l)
+
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/audit?actor=client:2&since="+now.Add(-time.Minute).Format(time.RFC3339), nil))
+	if w.Code != http.StatusOK {
+		t.Fatal("wrong status code", w)
+	}
+	var entries []AuditEntry
+	if err := json.NewDecoder(w.Body).Decode(&entries); err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Actor != "client:2" || entries[0].Seq != 2 {
+		t.Fatal("wrong entries", entries)
+	}
+
+	w = httptest.NewRecorder()
+	s.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/audit?until=yesterday", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Fatal("wrong status code", w)
+	}
+
+	w = httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/v1/audit", nil)
+	ctx := WithIdentity(r.Context(), &Identity{ClientID: "client:1", Permissions: defaultRole.Permissions})
+	s.ServeHTTP(w, r.WithContext(ctx))
+	if w.Code != http.StatusForbidden {
+		t.Fatal("wrong status code", w)
+	}
+}
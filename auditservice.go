@@ -0,0 +1,202 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/mmatczuk/proxy/log"
+)
+
+// errTaskNotFound is recorded as error of operations on unknown tasks.
+var errTaskNotFound = errors.New("task not found")
+
+type auditService struct {
+	Service
+	audit  *AuditLog
+	logger log.Logger
+}
+
+// NewAuditService returns service that records create, kill, pause, resume,
+// retry and shutdown operations of s in audit log, other operations are
+// passed through. Operations are recorded when they finish, failure to record
+// is logged.
+func NewAuditService(s Service, audit *AuditLog, logger log.Logger) Service {
+	if s == nil {
+		panic("missing service")
+	}
+	if audit == nil {
+		panic("missing audit log")
+	}
+	if logger == nil {
+		panic("missing logger")
+	}
+
+	return &auditService{
+		Service: s,
+		audit:   audit,
+		logger:  logger,
+	}
+}
+
+func (s *auditService) entry(ctx context.Context, action AuditAction, id TaskID) *AuditEntry {
+	e := &AuditEntry{
+		Time:      time.Now().UTC(),
+		Action:    action,
+		SourceIP:  SourceIPFromContext(ctx),
+		RequestID: RequestIDFromContext(ctx),
+		TaskID:    id,
+	}
+	if v := IdentityFromContext(ctx); v != nil {
+		e.Actor = v.ClientID
+		e.AuthMethod = v.Method
+		e.Role = v.Role
+	}
+	return e
+}
+
+func (s *auditService) record(e *AuditEntry, err error) {
+	e.Outcome = AuditSuccess
+	if err != nil {
+		e.Outcome = AuditFailure
+		e.Error = err.Error()
+	}
+
+	if err := s.audit.Record(e); err != nil {
+		log.Error(s.logger).Log(
+			"msg", "failed to record audit entry",
+			"action", e.Action,
+			"request_id", e.RequestID,
+			"err", err,
+		)
+	}
+}
+
+func (s *auditService) CreateTask(ctx context.Context, config *TaskConfig) (TaskID, error) {
+	id, err := s.Service.CreateTask(ctx, config)
+
+	e := s.entry(ctx, AuditCreateTask, id)
+	e.Config = redactConfig(config)
+	s.record(e, err)
+
+	return id, err
+}
+
+func (s *auditService) KillTask(ctx context.Context, id TaskID, opts KillOptions) (*TaskStatus, error) {
+	t, err := s.Service.KillTask(ctx, id, opts)
+
+	e := s.entry(ctx, AuditKillTask, id)
+	e.Reason = opts.Reason
+	e.Addrs = opts.Addrs
+	e.Cascade = opts.Cascade
+	s.record(e, statusError(t, err))
+
+	return t, err
+}
+
+func (s *auditService) PauseTask(ctx context.Context, id TaskID) (*TaskStatus, error) {
+	t, err := s.Service.PauseTask(ctx, id)
+	s.record(s.entry(ctx, AuditPauseTask, id), statusError(t, err))
+	return t, err
+}
+
+func (s *auditService) ResumeTask(ctx context.Context, id TaskID) (*TaskStatus, error) {
+	t, err := s.Service.ResumeTask(ctx, id)
+	s.record(s.entry(ctx, AuditResumeTask, id), statusError(t, err))
+	return t, err
+}
+
+func (s *auditService) RetryTask(ctx context.Context, id TaskID, opts RetryOptions) (TaskID, error) {
+	child, err := s.Service.RetryTask(ctx, id, opts)
+
+	e := s.entry(ctx, AuditRetryTask, id)
+	e.ChildID = child
+	e.Statuses = opts.Statuses
+	if err == nil && child == "" {
+		err = errTaskNotFound
+	}
+	s.record(e, err)
+
+	return child, err
+}
+
+func (s *auditService) Shutdown(ctx context.Context) error {
+	var err error
+	if v, ok := s.Service.(Shutdowner); ok {
+		err = v.Shutdown(ctx)
+	}
+	s.record(s.entry(ctx, AuditShutdown, ""), err)
+	return err
+}
+
+// statusError returns err or errTaskNotFound if task status is missing.
+func statusError(t *TaskStatus, err error) error {
+	if err == nil && t == nil {
+		return errTaskNotFound
+	}
+	return err
+}
+
+type auditServer struct {
+	audit *AuditLog
+}
+
+// NewAuditServer creates HTTP handler exposing audit log entries, callers
+// need audit permission.
+func NewAuditServer(audit *AuditLog) http.Handler {
+	if audit == nil {
+		panic("Missing audit log")
+	}
+
+	s := &auditServer{
+		audit: audit,
+	}
+
+	r := mux.NewRouter()
+	r.Use(routeMiddleware)
+	r.Path("/v1/audit").
+		Methods(http.MethodGet).
+		HandlerFunc(s.listEntries)
+
+	return r
+}
+
+func (s *auditServer) listEntries(w http.ResponseWriter, r *http.Request) {
+	if err := checkPermission(r.Context(), PermissionAudit); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	q := r.URL.Query()
+	filter := AuditFilter{
+		Actor:  q.Get("actor"),
+		Action: AuditAction(q.Get("action")),
+	}
+	var err error
+	if filter.Since, err = queryTime(q.Get("since")); err != nil {
+		http.Error(w, "invalid since: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if filter.Until, err = queryTime(q.Get("until")); err != nil {
+		http.Error(w, "invalid until: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	entries, err := s.audit.Query(filter)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, entries)
+}
+
+// queryTime parses RFC 3339 time, empty string is zero time.
+func queryTime(v string) (time.Time, error) {
+	if v == "" {
+		return time.Time{}, nil
+	}
+	return time.Parse(time.RFC3339, v)
+}
@@ -0,0 +1,189 @@
+package proxy
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// APIKeyHeader is the HTTP header carrying API key, alternatively the key can
+// be passed as a bearer token in Authorization header.
+const APIKeyHeader = "X-API-Key"
+
+// Identity represents authenticated caller.
+type Identity struct {
+	ClientID string
+	// Method is authentication method, "key" or "cert".
+	Method string
+	// Role is name of the role assigned by policy.
+	Role string
+	// Permissions lists operations the caller may perform.
+	Permissions []Permission
+	// AllTasks grants access to tasks of all clients.
+	AllTasks bool
+}
+
+type identityKey struct{}
+
+// WithIdentity returns context with caller identity.
+func WithIdentity(ctx context.Context, id *Identity) context.Context {
+	return context.WithValue(ctx, identityKey{}, id)
+}
+
+// IdentityFromContext returns caller identity stored in ctx or nil.
+func IdentityFromContext(ctx context.Context) *Identity {
+	id, _ := ctx.Value(identityKey{}).(*Identity)
+	return id
+}
+
+// AuthConfig specifies credentials accepted by Authenticator.
+type AuthConfig struct {
+	Keys  []AuthKey  `json:"keys"`
+	Certs []AuthCert `json:"certs"`
+}
+
+// AuthKey maps API key to client.
+type AuthKey struct {
+	ClientID string `json:"client_id"`
+	// SHA256 is hex encoded SHA-256 hash of the key.
+	SHA256 string `json:"sha256"`
+}
+
+// AuthCert maps client certificate to client.
+type AuthCert struct {
+	ClientID string `json:"client_id"`
+	// Subject is client certificate subject i.e. "CN=client,O=Org".
+	Subject string `json:"subject"`
+}
+
+// Authenticator authenticates HTTP requests with API keys or verified client
+// certificates.
+type Authenticator struct {
+	keys  map[[sha256.Size]byte]string
+	certs map[string]string
+}
+
+// NewAuthenticator creates authenticator from configuration.
+func NewAuthenticator(config *AuthConfig) (*Authenticator, error) {
+	a := &Authenticator{
+		keys:  make(map[[sha256.Size]byte]string),
+		certs: make(map[string]string),
+	}
+
+	for _, k := range config.Keys {
+		if k.ClientID == "" {
+			return nil, errors.New("key without client_id")
+		}
+		b, err := hex.DecodeString(k.SHA256)
+		if err != nil || len(b) != sha256.Size {
+			return nil, fmt.Errorf("invalid sha256 for client %s", k.ClientID)
+		}
+		var h [sha256.Size]byte
+		copy(h[:], b)
+		a.keys[h] = k.ClientID
+	}
+
+	for _, c := range config.Certs {
+		if c.ClientID == "" || c.Subject == "" {
+			return nil, errors.New("cert without client_id or subject")
+		}
+		a.certs[c.Subject] = c.ClientID
+	}
+
+	return a, nil
+}
+
+// LoadAuthenticator creates authenticator from JSON configuration file.
+func LoadAuthenticator(file string) (*Authenticator, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var c AuthConfig
+	if err := readJSON(&c, f); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %s", file, err)
+	}
+
+	return NewAuthenticator(&c)
+}
+
+// Authenticate returns identity of the caller or nil if request carries no
+// valid credentials.
+func (a *Authenticator) Authenticate(r *http.Request) *Identity {
+	if id := a.AuthenticateTLS(r.TLS); id != nil {
+		return id
+	}
+
+	key := r.Header.Get(APIKeyHeader)
+	if key == "" {
+		if v := r.Header.Get("Authorization"); strings.HasPrefix(v, "Bearer ") {
+			key = strings.TrimPrefix(v, "Bearer ")
+		}
+	}
+	return a.AuthenticateKey(key)
+}
+
+// CertAuth returns true if authenticator accepts client certificates.
+func (a *Authenticator) CertAuth() bool {
+	return len(a.certs) > 0
+}
+
+// AuthenticateTLS returns identity of the client with verified certificate or
+// nil.
+func (a *Authenticator) AuthenticateTLS(state *tls.ConnectionState) *Identity {
+	if state == nil || len(state.VerifiedChains) == 0 {
+		return nil
+	}
+
+	cert := state.VerifiedChains[0][0]
+	if clientID, ok := a.certs[cert.Subject.String()]; ok {
+		return &Identity{ClientID: clientID, Method: "cert"}
+	}
+	return nil
+}
+
+// AuthenticateKey returns identity of the client with API key or nil.
+func (a *Authenticator) AuthenticateKey(key string) *Identity {
+	if key == "" {
+		return nil
+	}
+
+	if clientID, ok := a.keys[sha256.Sum256([]byte(key))]; ok {
+		return &Identity{ClientID: clientID, Method: "key"}
+	}
+	return nil
+}
+
+// AuthMiddleware is a HTTP middleware that rejects unauthenticated requests
+// and stores caller identity in request context. Caller permissions are
+// assigned according to Policy, if Policy is nil callers have all
+// permissions on their own tasks.
+type AuthMiddleware struct {
+	Inner  http.Handler
+	Auth   *Authenticator
+	Policy *Policy
+}
+
+func (m AuthMiddleware) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	id := m.Auth.Authenticate(r)
+	if id == nil {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="proxy"`)
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	if !m.Policy.Authorize(id) {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	}
+
+	m.Inner.ServeHTTP(w, r.WithContext(WithIdentity(r.Context(), id)))
+}
@@ -0,0 +1,132 @@
+package proxy
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-kit/kit/log"
+	"github.com/golang/mock/gomock"
+)
+
+func testAuthenticator(t *testing.T) *Authenticator {
+	h := sha256.Sum256([]byte("secret"))
+	a, err := NewAuthenticator(&AuthConfig{
+		Keys: []AuthKey{
+			{ClientID: "client:key", SHA256: hex.EncodeToString(h[:])},
+		},
+		Certs: []AuthCert{
+			{ClientID: "client:cert", Subject: "CN=client,O=Org"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return a
+}
+
+func TestAuthMiddleware(t *testing.T) {
+	t.Parallel()
+
+	a := testAuthenticator(t)
+
+	cert := &x509.Certificate{Subject: pkix.Name{CommonName: "client", Organization: []string{"Org"}}}
+
+	table := []struct {
+		Name     string
+		Prepare  func(r *http.Request)
+		Status   int
+		ClientID string
+	}{
+		{
+			Name:    "no credentials",
+			Prepare: func(r *http.Request) {},
+			Status:  http.StatusUnauthorized,
+		},
+		{
+			Name:    "wrong key",
+			Prepare: func(r *http.Request) { r.Header.Set(APIKeyHeader, "foobar") },
+			Status:  http.StatusUnauthorized,
+		},
+		{
+			Name:     "api key",
+			Prepare:  func(r *http.Request) { r.Header.Set(APIKeyHeader, "secret") },
+			Status:   http.StatusOK,
+			ClientID: "client:key",
+		},
+		{
+			Name:     "bearer token",
+			Prepare:  func(r *http.Request) { r.Header.Set("Authorization", "Bearer secret") },
+			Status:   http.StatusOK,
+			ClientID: "client:key",
+		},
+		{
+			Name: "client certificate",
+			Prepare: func(r *http.Request) {
+				r.TLS = &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{cert}}}
+			},
+			Status:   http.StatusOK,
+			ClientID: "client:cert",
+		},
+		{
+			Name: "unverified client certificate",
+			Prepare: func(r *http.Request) {
+				r.TLS = &tls.ConnectionState{PeerCertificates: []*x509.Certificate{cert}}
+			},
+			Status: http.StatusUnauthorized,
+		},
+	}
+
+	for _, test := range table {
+		var clientID string
+		m := AuthMiddleware{
+			Inner: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				clientID = IdentityFromContext(r.Context()).ClientID
+			}),
+			Auth: a,
+		}
+
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		test.Prepare(r)
+		w := httptest.NewRecorder()
+		m.ServeHTTP(w, r)
+
+		if w.Code != test.Status {
+			t.Error(test.Name, "wrong status code", w.Code)
+		}
+		if clientID != test.ClientID {
+			t.Error(test.Name, "wrong client id", clientID)
+		}
+	}
+}
+
+func TestServiceCreateTaskIdentity(t *testing.T) {
+	t.Parallel()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	m := NewMockRemoteClient(ctrl)
+	m.EXPECT().Update(gomock.Any(), "addr0", "info").Return(nil).AnyTimes()
+
+	s := NewService(m, []string{"addr0"}, log.NewNopLogger())
+	ctx := WithIdentity(context.Background(), &Identity{ClientID: "client:1", Permissions: defaultRole.Permissions})
+
+	c := &TaskConfig{Mode: Sequential, Info: "info"}
+	if _, err := s.CreateTask(ctx, c); err != nil {
+		t.Fatal(err)
+	}
+	if c.ClientID != "client:1" {
+		t.Fatal("client id not set", c)
+	}
+
+	_, err := s.CreateTask(ctx, &TaskConfig{Mode: Sequential, Info: "info", ClientID: "client:2"})
+	if _, ok := err.(*PermissionError); !ok {
+		t.Fatal("expected permission error", err)
+	}
+}
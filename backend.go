@@ -0,0 +1,218 @@
+package proxy
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// BackendConfig specifies how to reach a legacy system.
+type BackendConfig struct {
+	// Addr is host:port or full URL of the backend.
+	Addr string `json:"addr"`
+	// Protocol is "http" (default) or "tcp", tcp backends are called with
+	// line protocol and accept only host:port address.
+	Protocol string `json:"protocol,omitempty"`
+	// Terminator ends lines of tcp backends, default is "\n".
+	Terminator string            `json:"terminator,omitempty"`
+	TLS        *BackendTLSConfig `json:"tls,omitempty"`
+	// Method is HTTP method, default is POST.
+	Method string `json:"method,omitempty"`
+	// Path replaces URL path of the backend.
+	Path string `json:"path,omitempty"`
+	// Headers are added to every request.
+	Headers map[string]string  `json:"headers,omitempty"`
+	Auth    *BackendAuthConfig `json:"auth,omitempty"`
+}
+
+// BackendAuthConfig specifies backend credentials, if Username is set basic
+// authentication is used otherwise the secret is sent in Header.
+type BackendAuthConfig struct {
+	Username string `json:"username,omitempty"`
+	// Header is a name of header carrying a static token i.e. "X-Auth-Token".
+	Header string `json:"header,omitempty"`
+	// Secret is password or token read from environment variable SecretEnv
+	// or file SecretFile.
+	SecretEnv  string `json:"secret_env,omitempty"`
+	SecretFile string `json:"secret_file,omitempty"`
+}
+
+func (c *BackendAuthConfig) secret() (string, error) {
+	switch {
+	case c.SecretEnv != "":
+		v, ok := os.LookupEnv(c.SecretEnv)
+		if !ok {
+			return "", fmt.Errorf("environment variable %s not set", c.SecretEnv)
+		}
+		return v, nil
+	case c.SecretFile != "":
+		b, err := ioutil.ReadFile(c.SecretFile)
+		if err != nil {
+			return "", err
+		}
+		return strings.TrimRight(string(b), "\r\n"), nil
+	default:
+		return "", errors.New("missing secret_env or secret_file")
+	}
+}
+
+// Profile returns request profile based on configuration, it returns nil if
+// backend uses defaults.
+func (c *BackendConfig) Profile() (*RequestProfile, error) {
+	if c.Method == "" && c.Path == "" && len(c.Headers) == 0 && c.Auth == nil {
+		return nil, nil
+	}
+
+	p := &RequestProfile{
+		Method: strings.ToUpper(c.Method),
+		Path:   c.Path,
+		Header: make(http.Header),
+	}
+	for k, v := range c.Headers {
+		p.Header.Set(k, v)
+	}
+
+	if c.Auth != nil {
+		secret, err := c.Auth.secret()
+		if err != nil {
+			return nil, fmt.Errorf("auth: %s", err)
+		}
+		switch {
+		case c.Auth.Username != "":
+			p.Username = c.Auth.Username
+			p.Password = secret
+		case c.Auth.Header != "":
+			p.Header.Set(c.Auth.Header, secret)
+		default:
+			return nil, errors.New("auth: missing username or header")
+		}
+	}
+
+	return p, nil
+}
+
+// BackendTLSConfig specifies TLS settings of a backend.
+type BackendTLSConfig struct {
+	// CAFile is a PEM encoded CA bundle used to verify the backend, if empty
+	// system roots are used.
+	CAFile string `json:"ca_file,omitempty"`
+	// CertFile and KeyFile specify client certificate.
+	CertFile           string `json:"cert_file,omitempty"`
+	KeyFile            string `json:"key_file,omitempty"`
+	InsecureSkipVerify bool   `json:"insecure_skip_verify,omitempty"`
+}
+
+// Load returns tls.Config based on configuration.
+func (c *BackendTLSConfig) Load() (*tls.Config, error) {
+	config := &tls.Config{
+		InsecureSkipVerify: c.InsecureSkipVerify,
+	}
+
+	if c.CAFile != "" {
+		b, err := ioutil.ReadFile(c.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		config.RootCAs = x509.NewCertPool()
+		if !config.RootCAs.AppendCertsFromPEM(b) {
+			return nil, fmt.Errorf("no certificates found in %s", c.CAFile)
+		}
+	}
+
+	if c.CertFile != "" || c.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+
+	return config, nil
+}
+
+// LoadBackends reads backend configurations from JSON file.
+func LoadBackends(file string) ([]BackendConfig, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var v struct {
+		Backends []BackendConfig `json:"backends"`
+	}
+	if err := readJSON(&v, f); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %s", file, err)
+	}
+
+	for _, b := range v.Backends {
+		if b.Addr == "" {
+			return nil, errors.New("backend without addr")
+		}
+		if err := b.validate(); err != nil {
+			return nil, fmt.Errorf("backend %s: %s", b.Addr, err)
+		}
+	}
+
+	return v.Backends, nil
+}
+
+func (c *BackendConfig) validate() error {
+	switch c.Protocol {
+	case "", "http":
+		if c.Terminator != "" {
+			return errors.New("terminator requires tcp protocol")
+		}
+		u, err := backendURL(c.Addr, c.TLS != nil)
+		if err != nil {
+			return err
+		}
+		if c.TLS != nil && u.Scheme != "https" {
+			return errors.New("tls requires https URL or host:port")
+		}
+		return nil
+	case "tcp":
+		if _, _, err := net.SplitHostPort(c.Addr); err != nil {
+			return err
+		}
+		if c.TLS != nil || c.Method != "" || c.Path != "" || len(c.Headers) > 0 || c.Auth != nil {
+			return errors.New("tls, method, path, headers and auth require http protocol")
+		}
+		return nil
+	default:
+		return fmt.Errorf("unsupported protocol %q", c.Protocol)
+	}
+}
+
+// RemoteClientOptions returns remote client options configuring backends.
+func RemoteClientOptions(backends []BackendConfig) ([]RemoteClientOption, error) {
+	var opts []RemoteClientOption
+	for _, b := range backends {
+		if b.Protocol == "tcp" {
+			opts = append(opts, WithBackendClient(b.Addr, NewTCPClient(b.Terminator)))
+			continue
+		}
+		if b.TLS != nil {
+			config, err := b.TLS.Load()
+			if err != nil {
+				return nil, fmt.Errorf("backend %s: %s", b.Addr, err)
+			}
+			opts = append(opts, WithBackendTLSConfig(b.Addr, config))
+		}
+
+		p, err := b.Profile()
+		if err != nil {
+			return nil, fmt.Errorf("backend %s: %s", b.Addr, err)
+		}
+		if p != nil {
+			opts = append(opts, WithRequestProfile(b.Addr, p))
+		}
+	}
+	return opts, nil
+}
@@ -0,0 +1,77 @@
+package proxy
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestBackendConfigProfile(t *testing.T) {
+	os.Setenv("PROXY_TEST_TOKEN", "token")
+	defer os.Unsetenv("PROXY_TEST_TOKEN")
+
+	dir, err := ioutil.TempDir("", "proxy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "password")
+	if err := ioutil.WriteFile(file, []byte("pass\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	c := BackendConfig{
+		Method:  "put",
+		Headers: map[string]string{"x-legacy": "1"},
+		Auth:    &BackendAuthConfig{Header: "X-Auth-Token", SecretEnv: "PROXY_TEST_TOKEN"},
+	}
+	p, err := c.Profile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Method != "PUT" || p.Header.Get("X-Legacy") != "1" || p.Header.Get("X-Auth-Token") != "token" {
+		t.Fatal("wrong profile", p)
+	}
+
+	c = BackendConfig{
+		Auth: &BackendAuthConfig{Username: "user", SecretFile: file},
+	}
+	p, err = c.Profile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Username != "user" || p.Password != "pass" {
+		t.Fatal("wrong credentials", p)
+	}
+
+	c = BackendConfig{
+		Auth: &BackendAuthConfig{Username: "user", SecretEnv: "PROXY_TEST_MISSING"},
+	}
+	if _, err := c.Profile(); err == nil {
+		t.Fatal("expected error")
+	}
+
+	c = BackendConfig{}
+	if p, err := c.Profile(); p != nil || err != nil {
+		t.Fatal("expected no profile", p, err)
+	}
+}
+
+func TestBackendConfigValidateTLS(t *testing.T) {
+	table := []struct {
+		Addr  string
+		Valid bool
+	}{
+		{"legacy:8443", true},
+		{"https://legacy:8443/update", true},
+		{"http://legacy:8080/update", false},
+	}
+
+	for _, v := range table {
+		c := BackendConfig{Addr: v.Addr, TLS: &BackendTLSConfig{}}
+		if err := c.validate(); (err == nil) != v.Valid {
+			t.Fatal(v.Addr, "unexpected result", err)
+		}
+	}
+}
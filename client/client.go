@@ -0,0 +1,325 @@
+// Package client provides Go client of the proxy REST API.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/mmatczuk/proxy"
+	"github.com/mmatczuk/proxy/trace"
+)
+
+// Client calls proxy over HTTP, it implements proxy.Service. Like other
+// proxy.Service implementations methods operating on a task return nil status
+// or empty ID and no error if the task does not exist.
+type Client struct {
+	baseURL     *url.URL
+	client      *http.Client
+	apiKey      string
+	minInterval time.Duration
+	maxInterval time.Duration
+}
+
+var _ proxy.Service = (*Client)(nil)
+
+// Option configures Client.
+type Option func(c *Client)
+
+// WithHTTPClient sets HTTP client used to call proxy, default is
+// http.DefaultClient.
+func WithHTTPClient(hc *http.Client) Option {
+	return func(c *Client) {
+		c.client = hc
+	}
+}
+
+// WithAPIKey sets API key sent in X-API-Key header.
+func WithAPIKey(key string) Option {
+	return func(c *Client) {
+		c.apiKey = key
+	}
+}
+
+// WithPollInterval sets polling interval of WaitForCompletion, the interval
+// starts at min and doubles on every poll up to max.
+func WithPollInterval(min, max time.Duration) Option {
+	return func(c *Client) {
+		c.minInterval = min
+		c.maxInterval = max
+	}
+}
+
+// New creates client of proxy listening at baseURL i.e.
+// "http://localhost:8080".
+func New(baseURL string, opts ...Option) (*Client, error) {
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("unsupported scheme %q", u.Scheme)
+	}
+
+	c := &Client{
+		baseURL:     u,
+		client:      http.DefaultClient,
+		minInterval: 100 * time.Millisecond,
+		maxInterval: 5 * time.Second,
+	}
+
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	return c, nil
+}
+
+// CreateTask creates a task and returns its ID.
+func (c *Client) CreateTask(ctx context.Context, config *proxy.TaskConfig) (proxy.TaskID, error) {
+	var id proxy.TaskID
+	if err := c.do(ctx, http.MethodPost, "/v1/task", config, &id); err != nil {
+		return "", err
+	}
+	return id, nil
+}
+
+// PlanTask returns remote calls a task created with config would make.
+func (c *Client) PlanTask(ctx context.Context, config *proxy.TaskConfig) (*proxy.Plan, error) {
+	var p proxy.Plan
+	if err := c.do(ctx, http.MethodPost, "/v1/task/plan", config, &p); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// TaskStatus returns status of the task.
+func (c *Client) TaskStatus(ctx context.Context, id proxy.TaskID) (*proxy.TaskStatus, error) {
+	var t proxy.TaskStatus
+	if err := c.do(ctx, http.MethodGet, taskPath(id, ""), nil, &t); err != nil {
+		return nil, notFound(err)
+	}
+	return &t, nil
+}
+
+// KillTask kills the task and returns its status.
+func (c *Client) KillTask(ctx context.Context, id proxy.TaskID, opts proxy.KillOptions) (*proxy.TaskStatus, error) {
+	wait := !opts.Async
+	body := struct {
+		Reason  string   `json:"reason,omitempty"`
+		Addrs   []string `json:"addrs,omitempty"`
+		Wait    bool     `json:"wait"`
+		Cascade bool     `json:"cascade,omitempty"`
+	}{opts.Reason, opts.Addrs, wait, opts.Cascade}
+
+	var t proxy.TaskStatus
+	if err := c.do(ctx, http.MethodPost, taskPath(id, "kill"), body, &t); err != nil {
+		return nil, notFound(err)
+	}
+	return &t, nil
+}
+
+// PauseTask pauses the task and returns its status.
+func (c *Client) PauseTask(ctx context.Context, id proxy.TaskID) (*proxy.TaskStatus, error) {
+	var t proxy.TaskStatus
+	if err := c.do(ctx, http.MethodPost, taskPath(id, "pause"), nil, &t); err != nil {
+		return nil, notFound(err)
+	}
+	return &t, nil
+}
+
+// ResumeTask resumes the paused task and returns its status.
+func (c *Client) ResumeTask(ctx context.Context, id proxy.TaskID) (*proxy.TaskStatus, error) {
+	var t proxy.TaskStatus
+	if err := c.do(ctx, http.MethodPost, taskPath(id, "resume"), nil, &t); err != nil {
+		return nil, notFound(err)
+	}
+	return &t, nil
+}
+
+// RetryTask creates a task retrying calls of a finished task and returns its
+// ID.
+func (c *Client) RetryTask(ctx context.Context, id proxy.TaskID, opts proxy.RetryOptions) (proxy.TaskID, error) {
+	body := struct {
+		Statuses []proxy.Status `json:"statuses,omitempty"`
+	}{opts.Statuses}
+
+	var child proxy.TaskID
+	if err := c.do(ctx, http.MethodPost, taskPath(id, "retry"), body, &child); err != nil {
+		return "", notFound(err)
+	}
+	return child, nil
+}
+
+// ListTasks returns status of all tasks visible to the caller.
+func (c *Client) ListTasks(ctx context.Context) ([]*proxy.TaskStatus, error) {
+	var l []*proxy.TaskStatus
+	if err := c.do(ctx, http.MethodGet, "/v1/task", nil, &l); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// Audit returns audit log entries matching filter, caller needs audit
+// permission.
+func (c *Client) Audit(ctx context.Context, filter proxy.AuditFilter) ([]proxy.AuditEntry, error) {
+	q := url.Values{}
+	if !filter.Since.IsZero() {
+		q.Set("since", filter.Since.Format(time.RFC3339Nano))
+	}
+	if !filter.Until.IsZero() {
+		q.Set("until", filter.Until.Format(time.RFC3339Nano))
+	}
+	if filter.Actor != "" {
+		q.Set("actor", filter.Actor)
+	}
+	if filter.Action != "" {
+		q.Set("action", string(filter.Action))
+	}
+
+	path := "/v1/audit"
+	if len(q) > 0 {
+		path += "?" + q.Encode()
+	}
+
+	var l []proxy.AuditEntry
+	if err := c.do(ctx, http.MethodGet, path, nil, &l); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// WaitForCompletion polls task status until the task is done or ctx is
+// done, it returns the final task status or ErrNotFound if the task does not
+// exist. When proxy responds with RateLimitError polling is delayed by
+// RetryAfter.
+func (c *Client) WaitForCompletion(ctx context.Context, id proxy.TaskID) (*proxy.TaskStatus, error) {
+	return c.Watch(ctx, id, nil)
+}
+
+// Watch works like WaitForCompletion and calls fn whenever task status
+// changes, if fn returns error watching stops. Polling interval is reset on
+// every change.
+func (c *Client) Watch(ctx context.Context, id proxy.TaskID, fn func(t *proxy.TaskStatus) error) (*proxy.TaskStatus, error) {
+	var last *proxy.TaskStatus
+	interval := c.minInterval
+	for {
+		t, err := c.TaskStatus(ctx, id)
+		wait := interval
+		if err != nil {
+			e, ok := err.(*RateLimitError)
+			if !ok {
+				return nil, err
+			}
+			if e.RetryAfter > wait {
+				wait = e.RetryAfter
+			}
+		} else {
+			if t == nil {
+				return nil, ErrNotFound
+			}
+			if fn != nil && (last == nil || !reflect.DeepEqual(t, last)) {
+				if err := fn(t); err != nil {
+					return nil, err
+				}
+				interval = c.minInterval
+				wait = interval
+			}
+			last = t
+			if t.State == proxy.StateDone {
+				return t, nil
+			}
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+
+		interval *= 2
+		if interval > c.maxInterval {
+			interval = c.maxInterval
+		}
+	}
+}
+
+// taskPath returns escaped path of task action.
+func taskPath(id proxy.TaskID, action string) string {
+	p := "/v1/task/" + url.PathEscape(string(id))
+	if action != "" {
+		p += "/" + action
+	}
+	return p
+}
+
+// do sends request with JSON encoded in and decodes JSON response to out,
+// path must be escaped.
+func (c *Client) do(ctx context.Context, method, path string, in, out interface{}) error {
+	var body io.Reader
+	if in != nil {
+		b, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(b)
+	}
+
+	u := *c.baseURL
+	if i := strings.IndexByte(path, '?'); i >= 0 {
+		u.RawQuery = path[i+1:]
+		path = path[:i]
+	}
+	u.RawPath = strings.TrimRight(u.EscapedPath(), "/") + path
+	p, err := url.PathUnescape(u.RawPath)
+	if err != nil {
+		return err
+	}
+	u.Path = p
+
+	req, err := http.NewRequest(method, u.String(), body)
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.apiKey != "" {
+		req.Header.Set(proxy.APIKeyHeader, c.apiKey)
+	}
+	if id := proxy.RequestIDFromContext(ctx); id != "" {
+		req.Header.Set(proxy.RequestIDHeader, id)
+	}
+	trace.Inject(ctx, req.Header)
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return responseError(resp)
+	}
+
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode response: %s", err)
+	}
+	return nil
+}
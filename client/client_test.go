@@ -0,0 +1,236 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/golang/mock/gomock"
+	"github.com/mmatczuk/proxy"
+)
+
+// newTestClient starts proxy HTTP server exposing service and returns client
+// connected to it.
+func newTestClient(t *testing.T, service proxy.Service) *Client {
+	s := httptest.NewServer(proxy.NewServer(service))
+	t.Cleanup(s.Close)
+
+	c, err := New(s.URL, WithPollInterval(10*time.Millisecond, 50*time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestClientCreateTask(t *testing.T) {
+	t.Parallel()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	config := &proxy.TaskConfig{
+		ClientID:    "f0a4fd40-44bf-4535-b807-632586645d6f",
+		Info:        "test",
+		Mode:        proxy.Sequential,
+		FailOnError: true,
+	}
+	m := proxy.NewMockService(ctrl)
+	m.EXPECT().CreateTask(gomock.Any(), config).Return(proxy.TaskID("test"), nil)
+	c := newTestClient(t, m)
+
+	id, err := c.CreateTask(context.Background(), config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id != "test" {
+		t.Fatal("wrong id", id)
+	}
+}
+
+func TestClientErrors(t *testing.T) {
+	t.Parallel()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	m := proxy.NewMockService(ctrl)
+	m.EXPECT().CreateTask(gomock.Any(), gomock.Any()).Return(proxy.TaskID(""), &proxy.ConfigError{Msg: "invalid mode"})
+	m.EXPECT().CreateTask(gomock.Any(), gomock.Any()).Return(proxy.TaskID(""), proxy.ErrShutdown)
+	m.EXPECT().TaskStatus(gomock.Any(), proxy.TaskID("missing")).Return(nil, nil).Times(2)
+	m.EXPECT().KillTask(gomock.Any(), proxy.TaskID("missing"), proxy.KillOptions{}).Return(nil, nil)
+	m.EXPECT().TaskStatus(gomock.Any(), proxy.TaskID("error")).Return(nil, errors.New("foobar"))
+	m.EXPECT().KillTask(gomock.Any(), proxy.TaskID("test"), proxy.KillOptions{}).Return(nil, &proxy.PermissionError{Msg: "kill not permitted"})
+	c := newTestClient(t, m)
+	ctx := context.Background()
+
+	_, err := c.CreateTask(ctx, &proxy.TaskConfig{})
+	if e, ok := err.(*proxy.ConfigError); !ok || e.Msg != "invalid mode" {
+		t.Fatal("expected config error, got", err)
+	}
+	_, err = c.CreateTask(ctx, &proxy.TaskConfig{})
+	if err != proxy.ErrShutdown {
+		t.Fatal("expected shutdown error, got", err)
+	}
+	if st, err := c.TaskStatus(ctx, "missing"); st != nil || err != nil {
+		t.Fatal("expected not found, got", st, err)
+	}
+	if st, err := c.KillTask(ctx, "missing", proxy.KillOptions{}); st != nil || err != nil {
+		t.Fatal("expected not found, got", st, err)
+	}
+	_, err = c.WaitForCompletion(ctx, "missing")
+	if err != ErrNotFound {
+		t.Fatal("expected not found, got", err)
+	}
+	_, err = c.TaskStatus(ctx, "error")
+	if e, ok := err.(*ServerError); !ok || e.StatusCode != http.StatusInternalServerError || e.Msg != "foobar" {
+		t.Fatal("expected server error, got", err)
+	}
+	_, err = c.KillTask(ctx, "test", proxy.KillOptions{})
+	if _, ok := err.(*proxy.PermissionError); !ok {
+		t.Fatal("expected permission error, got", err)
+	}
+}
+
+func TestClientEscapeTaskID(t *testing.T) {
+	t.Parallel()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	m := proxy.NewMockService(ctrl)
+	m.EXPECT().TaskStatus(gomock.Any(), proxy.TaskID("a b?c")).Return(&proxy.TaskStatus{ID: "a b?c"}, nil)
+	c := newTestClient(t, m)
+
+	st, err := c.TaskStatus(context.Background(), "a b?c")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st.ID != "a b?c" {
+		t.Fatal("wrong status", st)
+	}
+}
+
+func TestClientRateLimitError(t *testing.T) {
+	t.Parallel()
+
+	var calls int32
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			w.Header().Set("Retry-After", "0")
+			http.Error(w, "slow down", http.StatusTooManyRequests)
+			return
+		}
+		w.Write([]byte(`{"id":"test","state":"done","results":[]}`))
+	}))
+	defer s.Close()
+
+	c, err := New(s.URL, WithPollInterval(10*time.Millisecond, 10*time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = c.TaskStatus(context.Background(), "test")
+	if e, ok := err.(*RateLimitError); !ok || e.Msg != "slow down" {
+		t.Fatal("expected rate limit error, got", err)
+	}
+
+	atomic.StoreInt32(&calls, 0)
+	st, err := c.WaitForCompletion(context.Background(), "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st.State != proxy.StateDone {
+		t.Fatal("wrong state", st)
+	}
+}
+
+func TestClientWaitForCompletion(t *testing.T) {
+	t.Parallel()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	m := proxy.NewMockRemoteClient(ctrl)
+	m.EXPECT().Update(gomock.Any(), "a", "test").Return(nil).Do(func(ctx context.Context, addr, info string) {
+		time.Sleep(50 * time.Millisecond)
+	})
+	m.EXPECT().Update(gomock.Any(), "b", "test").Return(errors.New("foobar"))
+	c := newTestClient(t, proxy.NewService(m, []string{"a", "b"}, log.NewNopLogger()))
+	ctx := context.Background()
+
+	id, err := c.CreateTask(ctx, &proxy.TaskConfig{Info: "test", Mode: proxy.Sequential})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	st, err := c.WaitForCompletion(ctx, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []proxy.Result{
+		{Addr: "a", Status: proxy.Success},
+		{Addr: "b", Status: proxy.Failure, Msg: "foobar"},
+	}
+	if st.State != proxy.StateDone || !reflect.DeepEqual(st.Results, expected) {
+		t.Fatal("wrong status", st)
+	}
+}
+
+func TestClientWatch(t *testing.T) {
+	t.Parallel()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	m := proxy.NewMockRemoteClient(ctrl)
+	m.EXPECT().Update(gomock.Any(), "a", "test").Return(nil).Do(func(ctx context.Context, addr, info string) {
+		time.Sleep(50 * time.Millisecond)
+	})
+	c := newTestClient(t, proxy.NewService(m, []string{"a"}, log.NewNopLogger()))
+	ctx := context.Background()
+
+	id, err := c.CreateTask(ctx, &proxy.TaskConfig{Info: "test", Mode: proxy.Sequential})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var states []proxy.TaskState
+	_, err = c.Watch(ctx, id, func(t *proxy.TaskStatus) error {
+		states = append(states, t.State)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(states) < 2 || states[0] != proxy.StateRunning || states[len(states)-1] != proxy.StateDone {
+		t.Fatal("wrong states", states)
+	}
+}
+
+func TestClientWaitForCompletionCancel(t *testing.T) {
+	t.Parallel()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	m := proxy.NewMockService(ctrl)
+	m.EXPECT().TaskStatus(gomock.Any(), proxy.TaskID("test")).Return(&proxy.TaskStatus{
+		ID:    "test",
+		State: proxy.StateRunning,
+	}, nil).AnyTimes()
+	c := newTestClient(t, m)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	_, err := c.WaitForCompletion(ctx, "test")
+	if err != context.DeadlineExceeded {
+		t.Fatal("expected deadline exceeded, got", err)
+	}
+}
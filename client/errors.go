@@ -0,0 +1,75 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mmatczuk/proxy"
+)
+
+// ErrNotFound is returned if proxy responds with 404 Not Found, methods
+// operating on a task return nil status instead.
+var ErrNotFound = errors.New("task not found")
+
+// notFound returns nil if err is ErrNotFound.
+func notFound(err error) error {
+	if err == ErrNotFound {
+		return nil
+	}
+	return err
+}
+
+// RateLimitError is returned if proxy rejects request with 429 Too Many
+// Requests.
+type RateLimitError struct {
+	Msg string
+	// RetryAfter is the time to wait before retrying, it's zero if proxy did
+	// not specify it.
+	RetryAfter time.Duration
+}
+
+func (e *RateLimitError) Error() string {
+	return fmt.Sprintf("rate limited: %s", e.Msg)
+}
+
+// ServerError is returned if proxy responds with an unexpected status code.
+type ServerError struct {
+	StatusCode int
+	Msg        string
+}
+
+func (e *ServerError) Error() string {
+	return fmt.Sprintf("server error %d: %s", e.StatusCode, e.Msg)
+}
+
+// responseError returns error based on response status code, errors returned
+// by proxy service are mapped back to their types.
+func responseError(resp *http.Response) error {
+	b, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+	msg := strings.TrimSpace(string(b))
+
+	switch resp.StatusCode {
+	case http.StatusBadRequest:
+		return &proxy.ConfigError{Msg: msg}
+	case http.StatusForbidden:
+		return &proxy.PermissionError{Msg: msg}
+	case http.StatusNotFound:
+		return ErrNotFound
+	case http.StatusTooManyRequests:
+		e := &RateLimitError{Msg: msg}
+		if v, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil {
+			e.RetryAfter = time.Duration(v) * time.Second
+		}
+		return e
+	case http.StatusServiceUnavailable:
+		return proxy.ErrShutdown
+	default:
+		return &ServerError{StatusCode: resp.StatusCode, Msg: msg}
+	}
+}
@@ -1,54 +1,452 @@
 package main
 
 import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
 	"flag"
 	"fmt"
+	"io/ioutil"
+	"net"
 	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
-	"github.com/go-kit/kit/log"
+	kitlog "github.com/go-kit/kit/log"
 	"github.com/mmatczuk/proxy"
+	"github.com/mmatczuk/proxy/log"
+	"github.com/mmatczuk/proxy/rpc"
+	"github.com/mmatczuk/proxy/trace"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
 )
 
 func main() {
 	// http address
 	var httpAddr string
 	flag.StringVar(&httpAddr, "http", ":80", "HTTP bind address")
+	// grpc address
+	var grpcAddr string
+	flag.StringVar(&grpcAddr, "grpc", "", "gRPC bind address, if empty gRPC is disabled")
+	// trace output
+	var traceFile string
+	flag.StringVar(&traceFile, "trace-file", "", "write trace spans as JSON to file, use - for stdout")
+	// logging
+	var logLevel, logFormat string
+	flag.StringVar(&logLevel, "log-level", "info", "minimal log level: debug, info, warn or error")
+	flag.StringVar(&logFormat, "log-format", "logfmt", "log format: logfmt or json")
+	// authentication
+	var authFile, policyFile string
+	flag.StringVar(&authFile, "auth-file", "", "JSON file with API keys and client certificates, if empty authentication is disabled")
+	flag.StringVar(&policyFile, "policy-file", "", "JSON file with client roles, if empty clients have all permissions on their own tasks")
+	// TLS
+	var tlsCert, tlsKey, tlsClientCA string
+	flag.StringVar(&tlsCert, "tls-cert", "", "TLS certificate file, if set HTTPS is served")
+	flag.StringVar(&tlsKey, "tls-key", "", "TLS key file")
+	flag.StringVar(&tlsClientCA, "tls-client-ca", "", "CA bundle used to verify client certificates")
+	// timeouts
+	var readHeaderTimeout, writeTimeout, idleTimeout, shutdownTimeout time.Duration
+	flag.DurationVar(&readHeaderTimeout, "read-header-timeout", 10*time.Second, "HTTP read header timeout")
+	flag.DurationVar(&writeTimeout, "write-timeout", 60*time.Second, "HTTP write timeout")
+	flag.DurationVar(&idleTimeout, "idle-timeout", 120*time.Second, "HTTP keep-alive idle timeout")
+	flag.DurationVar(&shutdownTimeout, "shutdown-timeout", 30*time.Second, "time to wait for running tasks on shutdown before killing them")
+	// audit
+	var auditFile, auditKeyFile string
+	flag.StringVar(&auditFile, "audit-file", "", "append-only audit log file, if empty audit is disabled")
+	flag.StringVar(&auditKeyFile, "audit-key-file", "", "file with HMAC key of audit log entries, required with audit-file")
+	// backends
+	var backendFile string
+	flag.StringVar(&backendFile, "backend-file", "", "JSON file with backend configuration, listed backends are added to servers")
 
 	flag.Parse()
 
 	// remote addresses
 	addrs := flag.Args()
+	var backends []proxy.BackendConfig
+	if backendFile != "" {
+		var err error
+		backends, err = proxy.LoadBackends(backendFile)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		addrs = mergeAddrs(addrs, backends)
+	}
 	if len(addrs) == 0 {
 		fmt.Fprintln(os.Stderr, "provide list of servers")
 		os.Exit(1)
 	}
 
-	logger := logger()
-	client := proxy.NewRemoteClient()
+	logger, err := logger(logLevel, logFormat)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	opts, err := proxy.RemoteClientOptions(backends)
+	if err != nil {
+		log.Error(logger).Log(
+			"msg", "could not configure backends",
+			"err", err,
+		)
+		os.Exit(1)
+	}
+	client := proxy.NewRemoteClient(opts...)
+
+	if traceFile != "" {
+		w := os.Stdout
+		if traceFile != "-" {
+			f, err := os.OpenFile(traceFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+			if err != nil {
+				log.Error(logger).Log(
+					"msg", "could not open trace file",
+					"file", traceFile,
+					"err", err,
+				)
+				os.Exit(1)
+			}
+			defer f.Close()
+			w = f
+		}
+		trace.SetExporter(trace.NewJSONExporter(w))
+	}
+
+	if err := proxy.RegisterMetrics(prometheus.DefaultRegisterer); err != nil {
+		log.Error(logger).Log(
+			"msg", "could not register metrics",
+			"err", err,
+		)
+		os.Exit(1)
+	}
+
+	service := proxy.NewService(client, addrs, logger)
+
+	var audit *proxy.AuditLog
+	if auditFile != "" {
+		key, err := auditKey(auditKeyFile)
+		if err != nil {
+			log.Error(logger).Log(
+				"msg", "could not read audit key",
+				"file", auditKeyFile,
+				"err", err,
+			)
+			os.Exit(1)
+		}
+		audit, err = proxy.OpenAuditLog(auditFile, key)
+		if err != nil {
+			log.Error(logger).Log(
+				"msg", "could not open audit file",
+				"file", auditFile,
+				"err", err,
+			)
+			os.Exit(1)
+		}
+		defer func() {
+			seq, hash := audit.Head()
+			log.Info(logger).Log(
+				"msg", "audit log closed",
+				"seq", seq,
+				"hash", hash,
+			)
+			audit.Close()
+		}()
+		seq, hash := audit.Head()
+		log.Info(logger).Log(
+			"msg", "audit log opened",
+			"seq", seq,
+			"hash", hash,
+		)
+		service = proxy.NewAuditService(service, audit, logger)
+	}
+
+	var (
+		auth   *proxy.Authenticator
+		policy *proxy.Policy
+	)
+	if authFile != "" {
+		auth, err = proxy.LoadAuthenticator(authFile)
+		if err != nil {
+			log.Error(logger).Log(
+				"msg", "could not load auth file",
+				"file", authFile,
+				"err", err,
+			)
+			os.Exit(1)
+		}
+		if policyFile != "" {
+			policy, err = proxy.LoadPolicy(policyFile)
+			if err != nil {
+				log.Error(logger).Log(
+					"msg", "could not load policy file",
+					"file", policyFile,
+					"err", err,
+				)
+				os.Exit(1)
+			}
+		}
+	}
+
+	// client certificates are verified only over TLS, refuse to silently
+	// serve without them
+	if tlsClientCA != "" && (tlsCert == "" || tlsKey == "") {
+		log.Error(logger).Log(
+			"msg", "-tls-client-ca requires -tls-cert and -tls-key",
+		)
+		os.Exit(1)
+	}
+	if auth != nil && auth.CertAuth() && tlsClientCA == "" {
+		log.Error(logger).Log(
+			"msg", "client certificates in auth file require -tls-client-ca, -tls-cert and -tls-key",
+			"file", authFile,
+		)
+		os.Exit(1)
+	}
 
 	var server http.Handler
-	server = proxy.NewServer(proxy.NewService(client, addrs, logger))
-	server = proxy.LoggingMiddleware{server, logger}
+	server = proxy.NewServer(service)
+	if audit != nil {
+		api := http.NewServeMux()
+		api.Handle("/", server)
+		api.Handle("/v1/audit", proxy.NewAuditServer(audit))
+		server = api
+	}
+	if auth != nil {
+		server = proxy.AuthMiddleware{Inner: server, Auth: auth, Policy: policy}
+	}
+	server = proxy.LoggingMiddleware{Inner: server, Logger: logger}
+	server = proxy.SourceIPMiddleware{Inner: server}
+	server = proxy.RequestIDMiddleware{Inner: server}
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+	mux.Handle("/", server)
 
-	logger.Log(
+	srv := &http.Server{
+		Addr:              httpAddr,
+		Handler:           mux,
+		ReadHeaderTimeout: readHeaderTimeout,
+		WriteTimeout:      writeTimeout,
+		IdleTimeout:       idleTimeout,
+	}
+
+	if tlsClientCA != "" {
+		pool, err := certPool(tlsClientCA)
+		if err != nil {
+			log.Error(logger).Log(
+				"msg", "could not load client CA",
+				"file", tlsClientCA,
+				"err", err,
+			)
+			os.Exit(1)
+		}
+		srv.TLSConfig = &tls.Config{
+			ClientCAs:  pool,
+			ClientAuth: tls.VerifyClientCertIfGiven,
+		}
+	}
+
+	var grpcSrv *grpc.Server
+	if grpcAddr != "" {
+		grpcSrv = newGRPCServer(service, auth, policy, srv.TLSConfig, tlsCert, tlsKey, logger)
+		l, err := net.Listen("tcp", grpcAddr)
+		if err != nil {
+			log.Error(logger).Log(
+				"msg", "could not start gRPC",
+				"addr", grpcAddr,
+				"err", err,
+			)
+			os.Exit(1)
+		}
+		go grpcSrv.Serve(l)
+
+		log.Info(logger).Log(
+			"msg", "start gRPC",
+			"addr", grpcAddr,
+		)
+	}
+
+	stopped := make(chan struct{})
+	go func() {
+		shutdownOnSignal(srv, grpcSrv, service, shutdownTimeout, logger)
+		close(stopped)
+	}()
+
+	log.Info(logger).Log(
 		"msg", "start",
 		"addr", httpAddr,
 	)
 
-	err := http.ListenAndServe(httpAddr, server)
-	if err != nil {
-		logger.Log(
+	if tlsCert != "" {
+		err = srv.ListenAndServeTLS(tlsCert, tlsKey)
+	} else {
+		err = srv.ListenAndServe()
+	}
+	if err != nil && err != http.ErrServerClosed {
+		log.Error(logger).Log(
 			"msg", "could not start",
 			"addr", httpAddr,
 			"err", err,
 		)
+		os.Exit(1)
 	}
+	<-stopped
+
+	log.Info(logger).Log(
+		"msg", "stopped",
+	)
 }
 
-func logger() log.Logger {
-	var logger log.Logger
-	logger = log.NewLogfmtLogger(log.NewSyncWriter(os.Stdout))
-	logger = log.With(logger, "ts", log.DefaultTimestampUTC)
-	return logger
+// shutdownOnSignal waits for SIGTERM or SIGINT, stops accepting new tasks,
+// waits for running tasks and closes HTTP and gRPC servers.
+func shutdownOnSignal(srv *http.Server, grpcSrv *grpc.Server, service proxy.Service, timeout time.Duration, logger log.Logger) {
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, syscall.SIGTERM, syscall.SIGINT)
+	sig := <-c
+
+	log.Info(logger).Log(
+		"msg", "shutting down",
+		"signal", sig,
+		"timeout", timeout,
+	)
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	if s, ok := service.(proxy.Shutdowner); ok {
+		if err := s.Shutdown(ctx); err != nil {
+			log.Warn(logger).Log(
+				"msg", "killed running tasks",
+				"err", err,
+			)
+		}
+	}
+
+	// wait for in-flight HTTP requests and gRPC calls
+	ctx, cancel = context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if grpcSrv != nil {
+		done := make(chan struct{})
+		go func() {
+			grpcSrv.GracefulStop()
+			close(done)
+		}()
+		select {
+		case <-done:
+		case <-ctx.Done():
+			grpcSrv.Stop()
+		}
+	}
+	if err := srv.Shutdown(ctx); err != nil {
+		log.Error(logger).Log(
+			"msg", "could not shutdown HTTP server",
+			"err", err,
+		)
+	}
+}
+
+// newGRPCServer creates gRPC server sharing authentication and TLS settings
+// with HTTP server.
+func newGRPCServer(service proxy.Service, auth *proxy.Authenticator, policy *proxy.Policy, tlsConfig *tls.Config, tlsCert, tlsKey string, logger log.Logger) *grpc.Server {
+	var (
+		unary  = []grpc.UnaryServerInterceptor{rpc.RequestIDInterceptor{}.Unary, rpc.SourceIPInterceptor{}.Unary}
+		stream = []grpc.StreamServerInterceptor{rpc.RequestIDInterceptor{}.Stream, rpc.SourceIPInterceptor{}.Stream}
+	)
+	if auth != nil {
+		ai := rpc.AuthInterceptor{Auth: auth, Policy: policy}
+		unary = append(unary, ai.Unary)
+		stream = append(stream, ai.Stream)
+	}
+
+	opts := []grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(unary...),
+		grpc.ChainStreamInterceptor(stream...),
+	}
+
+	if tlsCert != "" {
+		cert, err := tls.LoadX509KeyPair(tlsCert, tlsKey)
+		if err != nil {
+			log.Error(logger).Log(
+				"msg", "could not load TLS certificate",
+				"err", err,
+			)
+			os.Exit(1)
+		}
+		config := &tls.Config{}
+		if tlsConfig != nil {
+			config = tlsConfig.Clone()
+		}
+		config.Certificates = []tls.Certificate{cert}
+		opts = append(opts, grpc.Creds(credentials.NewTLS(config)))
+	}
+
+	return rpc.NewServer(service, opts...)
+}
+
+// mergeAddrs appends addresses of backends not listed in addrs.
+func mergeAddrs(addrs []string, backends []proxy.BackendConfig) []string {
+	m := make(map[string]bool)
+	for _, addr := range addrs {
+		m[addr] = true
+	}
+	for _, b := range backends {
+		if !m[b.Addr] {
+			addrs = append(addrs, b.Addr)
+			m[b.Addr] = true
+		}
+	}
+	return addrs
+}
+
+func certPool(file string) (*x509.CertPool, error) {
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(b) {
+		return nil, fmt.Errorf("no certificates found in %s", file)
+	}
+
+	return pool, nil
+}
+
+// auditKey reads HMAC key of audit log entries, surrounding white space is
+// trimmed.
+func auditKey(file string) ([]byte, error) {
+	if file == "" {
+		return nil, errors.New("audit-key-file is required")
+	}
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	return bytes.TrimSpace(b), nil
+}
+
+func logger(level, format string) (log.Logger, error) {
+	l, err := log.ParseLevel(level)
+	if err != nil {
+		return nil, err
+	}
+
+	var logger kitlog.Logger
+	switch format {
+	case "logfmt":
+		logger = kitlog.NewLogfmtLogger(kitlog.NewSyncWriter(os.Stdout))
+	case "json":
+		logger = kitlog.NewJSONLogger(kitlog.NewSyncWriter(os.Stdout))
+	default:
+		return nil, fmt.Errorf("unknown log format %q", format)
+	}
+	logger = kitlog.With(logger, "ts", kitlog.DefaultTimestampUTC)
+
+	return log.NewFilter(logger, l), nil
 }
@@ -0,0 +1,738 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/mmatczuk/proxy"
+	"github.com/mmatczuk/proxy/client"
+)
+
+// Exit codes.
+const (
+	exitOK      = 0
+	exitError   = 1
+	exitUsage   = 2
+	exitFailure = 3
+	exitKilled  = 4
+)
+
+const usage = `Usage: proxyctl [flags] <command> [command flags] [args]
+
+Commands:
+  create   create a task
+  status   print task status
+  kill     kill a task
+  pause    stop dispatching calls of a sequential task
+  resume   resume a paused task
+  retry    retry failed, killed and ignored calls of a finished task
+  wait     wait for task to finish
+  list     list tasks
+  watch    print task status on every change until task is finished
+  audit    print audit log entries
+  verify   verify HMAC chain of audit log file
+
+Exit codes:
+  0  success
+  1  request failed
+  2  invalid usage
+  3  task has failed results or quorum or race task failed, or audit log
+     verification failed
+  4  task has killed or ignored results
+
+Quorum and race task exit code depends only on its verdict.
+
+Flags:
+`
+
+// command runs subcommand and returns exit code.
+type command func(ctx context.Context, c *client.Client, out *output, args []string) (int, error)
+
+var commands = map[string]command{
+	"create": create,
+	"status": status,
+	"kill":   kill,
+	"pause":  pause,
+	"resume": resume,
+	"retry":  retry,
+	"wait":   wait,
+	"list":   list,
+	"watch":  watch,
+	"audit":  audit,
+	"verify": verify,
+}
+
+func main() {
+	var addr, apiKey, format string
+	var timeout time.Duration
+	flag.StringVar(&addr, "addr", env("PROXY_ADDR", "http://localhost:80"), "proxy URL, defaults to PROXY_ADDR environment variable")
+	flag.StringVar(&apiKey, "api-key", os.Getenv("PROXY_API_KEY"), "API key, defaults to PROXY_API_KEY environment variable")
+	flag.StringVar(&format, "o", "table", "output format: table or json")
+	flag.DurationVar(&timeout, "timeout", 0, "overall timeout, 0 means no timeout")
+	flag.Usage = func() {
+		fmt.Fprint(os.Stderr, usage)
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(exitUsage)
+	}
+	cmd, ok := commands[flag.Arg(0)]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "unknown command %q\n", flag.Arg(0))
+		flag.Usage()
+		os.Exit(exitUsage)
+	}
+	if format != "table" && format != "json" {
+		fmt.Fprintf(os.Stderr, "unknown output format %q\n", format)
+		os.Exit(exitUsage)
+	}
+
+	c, err := client.New(addr, client.WithAPIKey(apiKey))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(exitUsage)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	go func() {
+		ch := make(chan os.Signal, 1)
+		signal.Notify(ch, syscall.SIGTERM, syscall.SIGINT)
+		<-ch
+		cancel()
+	}()
+
+	code, err := cmd(ctx, c, &output{w: os.Stdout, json: format == "json"}, flag.Args()[1:])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+	}
+	os.Exit(code)
+}
+
+func env(key, def string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return def
+}
+
+// taskID parses task ID from command arguments, it returns false and prints
+// error if arguments are invalid.
+func taskID(fs *flag.FlagSet, args []string) (proxy.TaskID, bool) {
+	if err := fs.Parse(args); err != nil {
+		return "", false
+	}
+	if fs.NArg() != 1 {
+		fmt.Fprintf(fs.Output(), "%s: expected task ID\n", fs.Name())
+		return "", false
+	}
+	return proxy.TaskID(fs.Arg(0)), true
+}
+
+func create(ctx context.Context, c *client.Client, out *output, args []string) (int, error) {
+	var (
+		config    proxy.TaskConfig
+		mode      string
+		info      string
+		infoFile  string
+		delay     time.Duration
+		hedge     time.Duration
+		notBefore string
+		dependsOn stringsFlag
+		condition string
+		stepsFile string
+		waitDone  bool
+		dryRun    bool
+	)
+	fs := flag.NewFlagSet("create", flag.ContinueOnError)
+	fs.StringVar(&config.ClientID, "client-id", "", "client ID")
+	fs.StringVar(&mode, "mode", string(proxy.Sequential), "task mode: sequential, parallel, two_phase, quorum or race")
+	fs.StringVar(&info, "info", "", "info sent to servers")
+	fs.StringVar(&infoFile, "info-file", "", "read info from file, use - for stdin")
+	fs.BoolVar(&config.FailOnError, "failonerror", false, "stop on first error")
+	fs.DurationVar(&delay, "delay", 0, "delay task execution")
+	fs.StringVar(&notBefore, "not-before", "", "delay task execution until RFC 3339 time")
+	fs.StringVar(&config.Schedule, "schedule", "", "cron expression of a recurring task")
+	fs.StringVar(&config.CompensationInfo, "compensation-info", "", "info sent to updated servers when task is killed before all calls succeeded or fails with failonerror")
+	fs.StringVar(&config.PrepareInfo, "prepare-info", "", "info sent in prepare phase of two_phase task, required in two_phase mode")
+	fs.StringVar(&config.AbortInfo, "abort-info", "", "info sent in abort phase of two_phase task, required in two_phase mode")
+	fs.Float64Var((*float64)(&config.Required), "required", 0, "number or ratio of servers required to commit two_phase task or to succeed in quorum task, 0 means all")
+	fs.BoolVar(&config.CancelRemaining, "cancel-remaining", false, "kill calls of quorum task still running when verdict is known")
+	fs.DurationVar(&hedge, "hedge-delay", 0, "time race task waits for a call before calling next server")
+	fs.Var(&dependsOn, "depends-on", "wait for the given task to finish before starting, may be repeated")
+	fs.StringVar(&condition, "condition", "", "when dependencies are met: succeeded (default) or finished")
+	fs.StringVar(&stepsFile, "steps-file", "", "read JSON list of steps of multi-step task from file")
+	fs.BoolVar(&waitDone, "wait", false, "wait for task to finish")
+	fs.BoolVar(&dryRun, "dry-run", false, "print planned calls without creating task")
+	if err := fs.Parse(args); err != nil {
+		return exitUsage, nil
+	}
+	if fs.NArg() != 0 {
+		return exitUsage, fmt.Errorf("create: unexpected arguments %v", fs.Args())
+	}
+	if dryRun && waitDone {
+		return exitUsage, fmt.Errorf("create: dry-run and wait are mutually exclusive")
+	}
+
+	config.Mode = proxy.TaskMode(mode)
+	config.Delay = proxy.Duration(delay)
+	config.HedgeDelay = proxy.Duration(hedge)
+	config.Condition = proxy.Condition(condition)
+	for _, v := range dependsOn {
+		config.DependsOn = append(config.DependsOn, proxy.TaskID(v))
+	}
+	if stepsFile != "" {
+		b, err := ioutil.ReadFile(stepsFile)
+		if err != nil {
+			return exitError, err
+		}
+		if err := json.Unmarshal(b, &config.Steps); err != nil {
+			return exitUsage, fmt.Errorf("create: invalid steps-file: %s", err)
+		}
+	}
+	if notBefore != "" {
+		t, err := time.Parse(time.RFC3339, notBefore)
+		if err != nil {
+			return exitUsage, fmt.Errorf("create: invalid not-before: %s", err)
+		}
+		config.NotBefore = &t
+	}
+
+	switch {
+	case info != "" && infoFile != "":
+		return exitUsage, fmt.Errorf("create: info and info-file are mutually exclusive")
+	case infoFile == "-":
+		b, err := ioutil.ReadAll(os.Stdin)
+		if err != nil {
+			return exitError, err
+		}
+		config.Info = string(b)
+	case infoFile != "":
+		b, err := ioutil.ReadFile(infoFile)
+		if err != nil {
+			return exitError, err
+		}
+		config.Info = string(b)
+	default:
+		config.Info = info
+	}
+
+	if dryRun {
+		p, err := c.PlanTask(ctx, &config)
+		if err != nil {
+			return exitError, err
+		}
+		out.plan(p)
+		return exitOK, nil
+	}
+
+	id, err := c.CreateTask(ctx, &config)
+	if err != nil {
+		return exitError, err
+	}
+
+	if !waitDone {
+		out.id(id)
+		return exitOK, nil
+	}
+
+	t, err := c.WaitForCompletion(ctx, id)
+	if err != nil {
+		return exitError, err
+	}
+	out.status(t)
+	return outcome(t), nil
+}
+
+func status(ctx context.Context, c *client.Client, out *output, args []string) (int, error) {
+	id, ok := taskID(flag.NewFlagSet("status", flag.ContinueOnError), args)
+	if !ok {
+		return exitUsage, nil
+	}
+
+	t, err := found(c.TaskStatus(ctx, id))
+	if err != nil {
+		return exitError, err
+	}
+	out.status(t)
+	return outcome(t), nil
+}
+
+func kill(ctx context.Context, c *client.Client, out *output, args []string) (int, error) {
+	var (
+		opts  proxy.KillOptions
+		addrs stringsFlag
+	)
+	fs := flag.NewFlagSet("kill", flag.ContinueOnError)
+	fs.StringVar(&opts.Reason, "reason", "", "kill reason recorded in task status")
+	fs.Var(&addrs, "addr", "kill only call to the given address, may be repeated")
+	fs.BoolVar(&opts.Async, "async", false, "do not wait for killed calls to finish")
+	fs.BoolVar(&opts.Cascade, "cascade", false, "kill also tasks depending on the task")
+	id, ok := taskID(fs, args)
+	if !ok {
+		return exitUsage, nil
+	}
+	opts.Addrs = addrs
+
+	t, err := found(c.KillTask(ctx, id, opts))
+	if err != nil {
+		return exitError, err
+	}
+	out.status(t)
+	return outcome(t), nil
+}
+
+func pause(ctx context.Context, c *client.Client, out *output, args []string) (int, error) {
+	id, ok := taskID(flag.NewFlagSet("pause", flag.ContinueOnError), args)
+	if !ok {
+		return exitUsage, nil
+	}
+
+	t, err := found(c.PauseTask(ctx, id))
+	if err != nil {
+		return exitError, err
+	}
+	out.status(t)
+	return exitOK, nil
+}
+
+func resume(ctx context.Context, c *client.Client, out *output, args []string) (int, error) {
+	id, ok := taskID(flag.NewFlagSet("resume", flag.ContinueOnError), args)
+	if !ok {
+		return exitUsage, nil
+	}
+
+	t, err := found(c.ResumeTask(ctx, id))
+	if err != nil {
+		return exitError, err
+	}
+	out.status(t)
+	return outcome(t), nil
+}
+
+func retry(ctx context.Context, c *client.Client, out *output, args []string) (int, error) {
+	var (
+		statuses stringsFlag
+		waitDone bool
+	)
+	fs := flag.NewFlagSet("retry", flag.ContinueOnError)
+	fs.Var(&statuses, "status", "retry calls with status: failure, killed or ignored, may be repeated")
+	fs.BoolVar(&waitDone, "wait", false, "wait for retry task to finish")
+	id, ok := taskID(fs, args)
+	if !ok {
+		return exitUsage, nil
+	}
+
+	var opts proxy.RetryOptions
+	for _, v := range statuses {
+		opts.Statuses = append(opts.Statuses, proxy.Status(v))
+	}
+	child, err := c.RetryTask(ctx, id, opts)
+	if err != nil {
+		return exitError, err
+	}
+	if child == "" {
+		return exitError, client.ErrNotFound
+	}
+
+	if !waitDone {
+		out.id(child)
+		return exitOK, nil
+	}
+
+	t, err := c.WaitForCompletion(ctx, child)
+	if err != nil {
+		return exitError, err
+	}
+	out.status(t)
+	return outcome(t), nil
+}
+
+func wait(ctx context.Context, c *client.Client, out *output, args []string) (int, error) {
+	id, ok := taskID(flag.NewFlagSet("wait", flag.ContinueOnError), args)
+	if !ok {
+		return exitUsage, nil
+	}
+
+	t, err := c.WaitForCompletion(ctx, id)
+	if err != nil {
+		return exitError, err
+	}
+	out.status(t)
+	return outcome(t), nil
+}
+
+func watch(ctx context.Context, c *client.Client, out *output, args []string) (int, error) {
+	id, ok := taskID(flag.NewFlagSet("watch", flag.ContinueOnError), args)
+	if !ok {
+		return exitUsage, nil
+	}
+
+	t, err := c.Watch(ctx, id, func(t *proxy.TaskStatus) error {
+		out.status(t)
+		return nil
+	})
+	if err != nil {
+		return exitError, err
+	}
+	return outcome(t), nil
+}
+
+func list(ctx context.Context, c *client.Client, out *output, args []string) (int, error) {
+	var state string
+	fs := flag.NewFlagSet("list", flag.ContinueOnError)
+	fs.StringVar(&state, "state", "", "show only tasks in state: waiting, scheduled, running, paused or done")
+	if err := fs.Parse(args); err != nil {
+		return exitUsage, nil
+	}
+
+	l, err := c.ListTasks(ctx)
+	if err != nil {
+		return exitError, err
+	}
+
+	tasks := []*proxy.TaskStatus{}
+	for _, t := range l {
+		if state == "" || string(t.State) == state {
+			tasks = append(tasks, t)
+		}
+	}
+	out.list(tasks)
+	return exitOK, nil
+}
+
+func audit(ctx context.Context, c *client.Client, out *output, args []string) (int, error) {
+	var (
+		filter       proxy.AuditFilter
+		since, until string
+		action       string
+	)
+	fs := flag.NewFlagSet("audit", flag.ContinueOnError)
+	fs.StringVar(&since, "since", "", "show entries since RFC 3339 time")
+	fs.StringVar(&until, "until", "", "show entries before RFC 3339 time")
+	fs.StringVar(&filter.Actor, "actor", "", "show entries of the given client")
+	fs.StringVar(&action, "action", "", "show entries of the given action i.e. create_task or kill_task")
+	if err := fs.Parse(args); err != nil {
+		return exitUsage, nil
+	}
+	filter.Action = proxy.AuditAction(action)
+
+	if since != "" {
+		t, err := time.Parse(time.RFC3339, since)
+		if err != nil {
+			return exitUsage, fmt.Errorf("audit: invalid since: %s", err)
+		}
+		filter.Since = t
+	}
+	if until != "" {
+		t, err := time.Parse(time.RFC3339, until)
+		if err != nil {
+			return exitUsage, fmt.Errorf("audit: invalid until: %s", err)
+		}
+		filter.Until = t
+	}
+
+	l, err := c.Audit(ctx, filter)
+	if err != nil {
+		return exitError, err
+	}
+	out.audit(l)
+	return exitOK, nil
+}
+
+func verify(ctx context.Context, c *client.Client, out *output, args []string) (int, error) {
+	var keyFile string
+
+	fs := flag.NewFlagSet("verify", flag.ContinueOnError)
+	fs.StringVar(&keyFile, "key-file", "", "file with HMAC key of audit log entries")
+	if err := fs.Parse(args); err != nil {
+		return exitUsage, nil
+	}
+	if fs.NArg() != 1 {
+		return exitUsage, fmt.Errorf("verify: expected audit log file")
+	}
+	if keyFile == "" {
+		return exitUsage, fmt.Errorf("verify: key-file is required")
+	}
+
+	key, err := ioutil.ReadFile(keyFile)
+	if err != nil {
+		return exitError, err
+	}
+
+	f, err := os.Open(fs.Arg(0))
+	if err != nil {
+		return exitError, err
+	}
+	defer f.Close()
+
+	last, err := proxy.VerifyAudit(f, bytes.TrimSpace(key))
+	if err != nil {
+		return exitFailure, fmt.Errorf("verify: %s", err)
+	}
+	out.verified(last)
+	return exitOK, nil
+}
+
+// stringsFlag is a flag that may be repeated.
+type stringsFlag []string
+
+func (f *stringsFlag) String() string {
+	return strings.Join(*f, ",")
+}
+
+func (f *stringsFlag) Set(v string) error {
+	*f = append(*f, v)
+	return nil
+}
+
+// found returns client.ErrNotFound if task status is missing.
+func found(t *proxy.TaskStatus, err error) (*proxy.TaskStatus, error) {
+	if err == nil && t == nil {
+		return nil, client.ErrNotFound
+	}
+	return t, err
+}
+
+// outcome returns exit code based on results of a finished task.
+func outcome(t *proxy.TaskStatus) int {
+	if t.State != proxy.StateDone {
+		return exitOK
+	}
+
+	switch t.Verdict {
+	case proxy.VerdictSucceeded:
+		return exitOK
+	case proxy.VerdictFailed:
+		return exitFailure
+	}
+
+	code := exitOK
+	for _, r := range t.Results {
+		switch r.Status {
+		case proxy.Failure, proxy.RollbackFailed:
+			return exitFailure
+		case proxy.Killed, proxy.Ignored, proxy.RolledBack:
+			code = exitKilled
+		}
+	}
+	return code
+}
+
+// output prints command results as JSON or tables.
+type output struct {
+	w    io.Writer
+	json bool
+}
+
+func (o *output) writeJSON(v interface{}) {
+	enc := json.NewEncoder(o.w)
+	enc.SetIndent("", "  ")
+	enc.Encode(v)
+}
+
+func (o *output) id(id proxy.TaskID) {
+	if o.json {
+		o.writeJSON(map[string]proxy.TaskID{"id": id})
+		return
+	}
+	fmt.Fprintln(o.w, id)
+}
+
+func (o *output) status(t *proxy.TaskStatus) {
+	if o.json {
+		o.writeJSON(t)
+		return
+	}
+
+	tw := tabwriter.NewWriter(o.w, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(tw, "ID:\t%s\n", t.ID)
+	fmt.Fprintf(tw, "State:\t%s\n", t.State)
+	if t.ClientID != "" {
+		fmt.Fprintf(tw, "Client:\t%s\n", t.ClientID)
+	}
+	if t.StartAt != nil {
+		fmt.Fprintf(tw, "Start at:\t%s\n", t.StartAt.Format(time.RFC3339))
+	}
+	if t.Schedule != "" {
+		fmt.Fprintf(tw, "Schedule:\t%s\n", t.Schedule)
+	}
+	if t.Verdict != "" {
+		fmt.Fprintf(tw, "Verdict:\t%s\n", t.Verdict)
+	}
+	if t.Step != "" {
+		fmt.Fprintf(tw, "Step:\t%s\n", t.Step)
+	}
+	if t.ParentID != "" {
+		fmt.Fprintf(tw, "Parent:\t%s\n", t.ParentID)
+	}
+	if len(t.DependsOn) > 0 {
+		ids := make([]string, len(t.DependsOn))
+		for i, v := range t.DependsOn {
+			ids[i] = string(v)
+		}
+		fmt.Fprintf(tw, "Depends on:\t%s\n", strings.Join(ids, ", "))
+	}
+	if t.KillReason != "" {
+		fmt.Fprintf(tw, "Kill reason:\t%s\n", t.KillReason)
+	}
+	if len(t.Children) > 0 {
+		ids := make([]string, len(t.Children))
+		for i, v := range t.Children {
+			ids[i] = string(v)
+		}
+		fmt.Fprintf(tw, "Children:\t%s\n", strings.Join(ids, ", "))
+	}
+	tw.Flush()
+
+	if len(t.Results) == 0 {
+		return
+	}
+	fmt.Fprintln(o.w)
+	tw = tabwriter.NewWriter(o.w, 0, 4, 2, ' ', 0)
+	var phases, steps bool
+	for _, r := range t.Results {
+		phases = phases || r.Phase != ""
+		steps = steps || r.Step != ""
+	}
+	switch {
+	case steps:
+		fmt.Fprintln(tw, "STEP\tADDR\tSTATUS\tMESSAGE")
+		for _, r := range t.Results {
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", r.Step, r.Addr, r.Status, r.Msg)
+		}
+	case phases:
+		fmt.Fprintln(tw, "ADDR\tPHASE\tSTATUS\tMESSAGE")
+		for _, r := range t.Results {
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", r.Addr, r.Phase, r.Status, r.Msg)
+		}
+	default:
+		fmt.Fprintln(tw, "ADDR\tSTATUS\tMESSAGE")
+		for _, r := range t.Results {
+			fmt.Fprintf(tw, "%s\t%s\t%s\n", r.Addr, r.Status, r.Msg)
+		}
+	}
+	tw.Flush()
+}
+
+func (o *output) plan(p *proxy.Plan) {
+	if o.json {
+		o.writeJSON(p)
+		return
+	}
+
+	tw := tabwriter.NewWriter(o.w, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(tw, "Mode:\t%s\n", p.Mode)
+	fmt.Fprintf(tw, "Fail on error:\t%t\n", p.FailOnError)
+	if p.StartAt != nil {
+		fmt.Fprintf(tw, "Start at:\t%s\n", p.StartAt.Format(time.RFC3339))
+	}
+	if p.Schedule != "" {
+		fmt.Fprintf(tw, "Schedule:\t%s\n", p.Schedule)
+	}
+	tw.Flush()
+
+	fmt.Fprintln(o.w)
+	tw = tabwriter.NewWriter(o.w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "STEP\tADDR\tINFO")
+	for i, s := range p.Steps {
+		for _, c := range s.Calls {
+			step := fmt.Sprint(i + 1)
+			if c.Step != "" {
+				step += " " + c.Step
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%d bytes\n", step, c.Addr, len(c.Info))
+		}
+	}
+	tw.Flush()
+}
+
+func (o *output) list(tasks []*proxy.TaskStatus) {
+	if o.json {
+		o.writeJSON(tasks)
+		return
+	}
+
+	tw := tabwriter.NewWriter(o.w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tSTATE\tCLIENT\tRESULTS")
+	for _, t := range tasks {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", t.ID, t.State, t.ClientID, summary(t.Results))
+	}
+	tw.Flush()
+}
+
+func (o *output) audit(entries []proxy.AuditEntry) {
+	if o.json {
+		o.writeJSON(entries)
+		return
+	}
+
+	tw := tabwriter.NewWriter(o.w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "SEQ\tTIME\tACTION\tACTOR\tSOURCE\tTASK\tOUTCOME")
+	for _, e := range entries {
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t%s\t%s\t%s\n", e.Seq, e.Time.Format(time.RFC3339), e.Action, e.Actor, e.SourceIP, e.TaskID, e.Outcome)
+	}
+	tw.Flush()
+}
+
+// verified prints result of audit log verification, last is nil if the log
+// is empty.
+func (o *output) verified(last *proxy.AuditEntry) {
+	var (
+		n    uint64
+		hash string
+	)
+	if last != nil {
+		n, hash = last.Seq, last.Hash
+	}
+
+	if o.json {
+		o.writeJSON(map[string]interface{}{"entries": n, "hash": hash})
+		return
+	}
+
+	tw := tabwriter.NewWriter(o.w, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(tw, "Entries:\t%d\n", n)
+	if hash != "" {
+		fmt.Fprintf(tw, "Last hash:\t%s\n", hash)
+	}
+	tw.Flush()
+}
+
+// summary returns number of results per status i.e. "2 success, 1 failure".
+func summary(results []proxy.Result) string {
+	var (
+		order  []proxy.Status
+		counts = make(map[proxy.Status]int)
+	)
+	for _, r := range results {
+		if counts[r.Status] == 0 {
+			order = append(order, r.Status)
+		}
+		counts[r.Status]++
+	}
+
+	parts := make([]string, len(order))
+	for i, s := range order {
+		parts[i] = fmt.Sprintf("%d %s", counts[s], s)
+	}
+	return strings.Join(parts, ", ")
+}
@@ -1,21 +1,75 @@
 package main
 
 import (
+	"bufio"
+	"bytes"
 	"flag"
+	"fmt"
+	"net"
 	"net/http"
+	"os"
 )
 
 func main() {
+	var mode, terminator string
+	flag.StringVar(&mode, "mode", "http", "protocol: http or tcp")
+	flag.StringVar(&terminator, "terminator", "\n", "line terminator in tcp mode")
 	flag.Parse()
 
 	for _, addr := range flag.Args() {
 		addr := addr
+		switch mode {
+		case "http":
+			go func() {
+				http.ListenAndServe(addr, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					w.Write([]byte("OK"))
+				}))
+			}()
+		case "tcp":
+			l, err := net.Listen("tcp", addr)
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(1)
+			}
+			go serveTCP(l, terminator)
+		default:
+			fmt.Fprintf(os.Stderr, "unknown mode %q\n", mode)
+			os.Exit(1)
+		}
+	}
+
+	select {}
+}
+
+// serveTCP replies "OK" to every line terminated with terminator.
+func serveTCP(l net.Listener, terminator string) {
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
 		go func() {
-			http.ListenAndServe(addr, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-				w.Write([]byte("OK"))
-			}))
+			defer conn.Close()
+
+			s := bufio.NewScanner(conn)
+			s.Split(splitLines([]byte(terminator)))
+			for s.Scan() {
+				if _, err := conn.Write([]byte("OK" + terminator)); err != nil {
+					return
+				}
+			}
 		}()
 	}
+}
 
-	select {}
+func splitLines(terminator []byte) bufio.SplitFunc {
+	return func(data []byte, atEOF bool) (advance int, token []byte, err error) {
+		if i := bytes.Index(data, terminator); i >= 0 {
+			return i + len(terminator), data[:i], nil
+		}
+		if atEOF && len(data) > 0 {
+			return len(data), data, nil
+		}
+		return 0, nil, nil
+	}
 }
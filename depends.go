@@ -0,0 +1,88 @@
+package proxy
+
+import (
+	"fmt"
+
+	"github.com/mmatczuk/proxy/log"
+)
+
+// waitDeps blocks until tasks the task depends on are done, it returns false
+// if task was killed in the meantime. If a dependency does not meet the
+// condition the task is killed.
+func (t *task) waitDeps() bool {
+	log.Info(t.logger).Log(
+		"msg", "task waiting",
+		"depends_on", len(t.deps),
+	)
+
+	for _, d := range t.deps {
+		select {
+		case <-d.done:
+		case <-t.context.Done():
+			return false
+		}
+		// select picks at random when both are ready, kill takes precedence
+		if t.context.Err() != nil {
+			return false
+		}
+
+		if t.config.Condition != ConditionFinished && !d.succeeded() {
+			t.kill(fmt.Sprintf("dependency %s did not succeed", d.ID()))
+			return false
+		}
+	}
+
+	return true
+}
+
+// succeeded returns true if task is done and reached its goal. Quorum and
+// race tasks succeed according to the verdict, two-phase tasks if committed
+// and all prepared addresses committed successfully, other tasks if all calls
+// succeeded.
+func (t *task) succeeded() bool {
+	if t.state() != StateDone {
+		return false
+	}
+
+	s := t.status()
+	if s.Verdict != "" {
+		return s.Verdict == VerdictSucceeded
+	}
+
+	if t.config.Mode == TwoPhase {
+		committed := false
+		for _, r := range s.Results {
+			switch r.Phase {
+			case PhaseCommit:
+				if r.Status != Success {
+					return false
+				}
+				committed = true
+			case PhaseAbort:
+				return false
+			}
+		}
+		return committed
+	}
+
+	for _, r := range s.Results {
+		if r.Status != Success {
+			return false
+		}
+	}
+	return true
+}
+
+// addDependent records task depending on this task.
+func (t *task) addDependent(d *task) {
+	t.mu.Lock()
+	t.dependents = append(t.dependents, d)
+	t.mu.Unlock()
+}
+
+// dependentTasks returns tasks depending on this task.
+func (t *task) dependentTasks() []*task {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]*task(nil), t.dependents...)
+}
@@ -0,0 +1,240 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/go-kit/kit/log"
+	"github.com/golang/mock/gomock"
+)
+
+func TestServiceDependsOn(t *testing.T) {
+	t.Parallel()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	release := make(chan struct{})
+
+	m := NewMockRemoteClient(ctrl)
+	gomock.InOrder(
+		m.EXPECT().Update(gomock.Any(), "addr0", "db").Return(nil).Do(func(ctx context.Context, addr, info string) {
+			<-release
+		}),
+		m.EXPECT().Update(gomock.Any(), "addr0", "app").Return(nil),
+	)
+
+	s := NewService(m, []string{"addr0"}, log.NewNopLogger()).(*service)
+	ctx := context.Background()
+
+	db, err := s.CreateTask(ctx, &TaskConfig{Mode: Sequential, Info: "db"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	app, err := s.CreateTask(ctx, &TaskConfig{Mode: Sequential, Info: "app", DependsOn: []TaskID{db}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	st, _ := s.TaskStatus(ctx, app)
+	if st.State != StateWaiting {
+		t.Fatal("wrong state", st)
+	}
+
+	close(release)
+	<-s.tasks[app].done
+
+	st, _ = s.TaskStatus(ctx, app)
+	if !reflect.DeepEqual(st, &TaskStatus{
+		ID:        app,
+		State:     StateDone,
+		DependsOn: []TaskID{db},
+		Results: []Result{
+			{
+				Addr:   "addr0",
+				Status: Success,
+			},
+		},
+	}) {
+		t.Fatal("wrong status", st)
+	}
+}
+
+func TestServiceDependsOnFailed(t *testing.T) {
+	t.Parallel()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	m := NewMockRemoteClient(ctrl)
+	gomock.InOrder(
+		m.EXPECT().Update(gomock.Any(), "addr0", "db").Return(errors.New("boom")),
+		m.EXPECT().Update(gomock.Any(), "addr0", "cleanup").Return(nil),
+	)
+
+	s := NewService(m, []string{"addr0"}, log.NewNopLogger()).(*service)
+	ctx := context.Background()
+
+	db, err := s.CreateTask(ctx, &TaskConfig{Mode: Sequential, Info: "db"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-s.tasks[db].done
+
+	app, err := s.CreateTask(ctx, &TaskConfig{Mode: Sequential, Info: "app", DependsOn: []TaskID{db}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-s.tasks[app].done
+
+	reason := "dependency " + string(db) + " did not succeed"
+	st, _ := s.TaskStatus(ctx, app)
+	if !reflect.DeepEqual(st, &TaskStatus{
+		ID:         app,
+		State:      StateDone,
+		DependsOn:  []TaskID{db},
+		KillReason: reason,
+		Results: []Result{
+			{
+				Addr:       "addr0",
+				Status:     Ignored,
+				KillReason: reason,
+			},
+		},
+	}) {
+		t.Fatal("wrong status", st)
+	}
+
+	cleanup, err := s.CreateTask(ctx, &TaskConfig{
+		Mode:      Sequential,
+		Info:      "cleanup",
+		DependsOn: []TaskID{db, app},
+		Condition: ConditionFinished,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-s.tasks[cleanup].done
+
+	st, _ = s.TaskStatus(ctx, cleanup)
+	if st.Results[0].Status != Success {
+		t.Fatal("wrong status", st)
+	}
+}
+
+func TestServiceDependsOnTwoPhasePartialCommit(t *testing.T) {
+	t.Parallel()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	m := NewMockRemoteClient(ctrl)
+	for _, addr := range []string{"addr0", "addr1"} {
+		m.EXPECT().Update(phaseIs(PhasePrepare), addr, "prepare").Return(nil)
+	}
+	m.EXPECT().Update(phaseIs(PhaseCommit), "addr0", "db").Return(nil)
+	m.EXPECT().Update(phaseIs(PhaseCommit), "addr1", "db").Return(errors.New("boom"))
+
+	s := NewService(m, []string{"addr0", "addr1"}, log.NewNopLogger()).(*service)
+	ctx := context.Background()
+
+	db, err := s.CreateTask(ctx, &TaskConfig{Mode: TwoPhase, Info: "db", PrepareInfo: "prepare", AbortInfo: "abort"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-s.tasks[db].done
+
+	app, err := s.CreateTask(ctx, &TaskConfig{Mode: Parallel, Info: "app", DependsOn: []TaskID{db}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-s.tasks[app].done
+
+	st, _ := s.TaskStatus(ctx, app)
+	if st.KillReason != "dependency "+string(db)+" did not succeed" || st.Results[0].Status != Ignored {
+		t.Fatal("wrong status", st)
+	}
+}
+
+func TestServiceDependsOnInvalid(t *testing.T) {
+	t.Parallel()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	m := NewMockRemoteClient(ctrl)
+	m.EXPECT().Update(gomock.Any(), "addr0", "db").Return(nil)
+
+	s := NewService(m, []string{"addr0"}, log.NewNopLogger()).(*service)
+	ctx := context.Background()
+
+	db, err := s.CreateTask(ctx, &TaskConfig{Mode: Sequential, Info: "db"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-s.tasks[db].done
+
+	table := []*TaskConfig{
+		{Mode: Sequential, DependsOn: []TaskID{"missing"}},
+		{Mode: Sequential, DependsOn: []TaskID{db, db}},
+		{Mode: Sequential, Condition: ConditionFinished},
+		{Mode: Sequential, DependsOn: []TaskID{db}, Condition: "always"},
+		{Mode: Sequential, DependsOn: []TaskID{db}, Schedule: "@every 1m"},
+	}
+
+	for i, config := range table {
+		_, err := s.CreateTask(ctx, config)
+		if _, ok := err.(*ConfigError); !ok {
+			t.Fatal(i, "expected config error", err)
+		}
+	}
+}
+
+func TestServiceKillTaskCascade(t *testing.T) {
+	t.Parallel()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	started := make(chan struct{})
+
+	m := NewMockRemoteClient(ctrl)
+	m.EXPECT().Update(gomock.Any(), "addr0", "db").Return(context.Canceled).Do(func(ctx context.Context, addr, info string) {
+		close(started)
+		<-ctx.Done()
+	})
+
+	s := NewService(m, []string{"addr0"}, log.NewNopLogger()).(*service)
+	ctx := context.Background()
+
+	db, err := s.CreateTask(ctx, &TaskConfig{Mode: Sequential, Info: "db"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	app, err := s.CreateTask(ctx, &TaskConfig{Mode: Sequential, Info: "app", DependsOn: []TaskID{db}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cache, err := s.CreateTask(ctx, &TaskConfig{
+		Mode:      Sequential,
+		Info:      "cache",
+		DependsOn: []TaskID{app},
+		Condition: ConditionFinished,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-started
+
+	if _, err := s.KillTask(ctx, db, KillOptions{Reason: "test", Addrs: []string{"addr0"}, Cascade: true}); err == nil {
+		t.Fatal("expected error")
+	}
+
+	if _, err := s.KillTask(ctx, db, KillOptions{Reason: "test", Cascade: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, id := range []TaskID{app, cache} {
+		st, _ := s.TaskStatus(ctx, id)
+		if st.State != StateDone || st.KillReason != "test" || st.Results[0].Status != Ignored {
+			t.Fatal("wrong status", st)
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package log
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Level specifies log record severity.
+type Level int
+
+// Level values.
+const (
+	LevelDebug Level = iota
+	LevelInfo
+	LevelWarn
+	LevelError
+)
+
+var levelNames = []string{"debug", "info", "warn", "error"}
+
+func (l Level) String() string {
+	if l < LevelDebug || l > LevelError {
+		return fmt.Sprintf("level(%d)", int(l))
+	}
+	return levelNames[l]
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (l Level) MarshalText() ([]byte, error) {
+	return []byte(l.String()), nil
+}
+
+// ParseLevel returns level by name.
+func ParseLevel(s string) (Level, error) {
+	for i, name := range levelNames {
+		if strings.EqualFold(s, name) {
+			return Level(i), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown log level %q", s)
+}
+
+// LevelKey is the key of the level value in log records.
+const LevelKey = "level"
+
+// Debug returns a logger that logs records at debug level.
+func Debug(logger Logger) Logger {
+	return With(logger, LevelKey, LevelDebug)
+}
+
+// Info returns a logger that logs records at info level.
+func Info(logger Logger) Logger {
+	return With(logger, LevelKey, LevelInfo)
+}
+
+// Warn returns a logger that logs records at warn level.
+func Warn(logger Logger) Logger {
+	return With(logger, LevelKey, LevelWarn)
+}
+
+// Error returns a logger that logs records at error level.
+func Error(logger Logger) Logger {
+	return With(logger, LevelKey, LevelError)
+}
+
+type filter struct {
+	logger Logger
+	min    Level
+}
+
+// NewFilter returns a logger that drops records with level lower than min,
+// records without level are treated as info.
+func NewFilter(logger Logger, min Level) Logger {
+	return &filter{logger: logger, min: min}
+}
+
+func (f *filter) Log(keyvals ...interface{}) error {
+	l := LevelInfo
+	for i := 0; i+1 < len(keyvals); i += 2 {
+		if v, ok := keyvals[i+1].(Level); ok && keyvals[i] == LevelKey {
+			l = v
+		}
+	}
+
+	if l < f.min {
+		return nil
+	}
+
+	return f.logger.Log(keyvals...)
+}
@@ -5,3 +5,32 @@ package log
 type Logger interface {
 	Log(keyvals ...interface{}) error
 }
+
+type context struct {
+	logger  Logger
+	keyvals []interface{}
+}
+
+// With returns a logger that adds keyvals to every log record, it's used to
+// add contextual fields such as task or client ID.
+func With(logger Logger, keyvals ...interface{}) Logger {
+	if len(keyvals) == 0 {
+		return logger
+	}
+
+	if c, ok := logger.(*context); ok {
+		kvs := make([]interface{}, 0, len(c.keyvals)+len(keyvals))
+		kvs = append(kvs, c.keyvals...)
+		kvs = append(kvs, keyvals...)
+		return &context{logger: c.logger, keyvals: kvs}
+	}
+
+	return &context{logger: logger, keyvals: keyvals}
+}
+
+func (c *context) Log(keyvals ...interface{}) error {
+	kvs := make([]interface{}, 0, len(c.keyvals)+len(keyvals))
+	kvs = append(kvs, c.keyvals...)
+	kvs = append(kvs, keyvals...)
+	return c.logger.Log(kvs...)
+}
@@ -0,0 +1,63 @@
+package log
+
+import (
+	"reflect"
+	"testing"
+)
+
+type recorder struct {
+	records [][]interface{}
+}
+
+func (r *recorder) Log(keyvals ...interface{}) error {
+	r.records = append(r.records, keyvals)
+	return nil
+}
+
+func TestWith(t *testing.T) {
+	t.Parallel()
+
+	r := &recorder{}
+	l := With(With(r, "task", "t1"), "client", "c1")
+	Info(l).Log("msg", "hello")
+
+	if !reflect.DeepEqual(r.records, [][]interface{}{
+		{"task", "t1", "client", "c1", LevelKey, LevelInfo, "msg", "hello"},
+	}) {
+		t.Fatal("wrong records", r.records)
+	}
+}
+
+func TestFilter(t *testing.T) {
+	t.Parallel()
+
+	r := &recorder{}
+	l := NewFilter(r, LevelWarn)
+
+	Debug(l).Log("msg", "debug")
+	Info(l).Log("msg", "info")
+	l.Log("msg", "no level")
+	Warn(l).Log("msg", "warn")
+	Error(With(l, "task", "t1")).Log("msg", "error")
+
+	if !reflect.DeepEqual(r.records, [][]interface{}{
+		{LevelKey, LevelWarn, "msg", "warn"},
+		{"task", "t1", LevelKey, LevelError, "msg", "error"},
+	}) {
+		t.Fatal("wrong records", r.records)
+	}
+}
+
+func TestParseLevel(t *testing.T) {
+	t.Parallel()
+
+	for _, l := range []Level{LevelDebug, LevelInfo, LevelWarn, LevelError} {
+		v, err := ParseLevel(l.String())
+		if err != nil || v != l {
+			t.Fatal("wrong level", l, v, err)
+		}
+	}
+	if _, err := ParseLevel("verbose"); err == nil {
+		t.Fatal("expected error")
+	}
+}
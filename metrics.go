@@ -0,0 +1,63 @@
+package proxy
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Prometheus metrics, they need to be registered with RegisterMetrics.
+var (
+	tasksCreated = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "proxy",
+		Name:      "tasks_created_total",
+		Help:      "Number of created tasks by mode.",
+	}, []string{"mode"})
+
+	tasksRunning = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: "proxy",
+		Name:      "tasks_running",
+		Help:      "Number of running tasks.",
+	})
+
+	remoteCalls = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "proxy",
+		Name:      "remote_calls_total",
+		Help:      "Number of remote calls by address and outcome.",
+	}, []string{"addr", "outcome"})
+
+	remoteCallsInFlight = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: "proxy",
+		Name:      "remote_calls_in_flight",
+		Help:      "Number of remote calls in progress.",
+	})
+
+	remoteCallDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "proxy",
+		Name:      "remote_call_duration_seconds",
+		Help:      "Remote call latency by address.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"addr"})
+
+	httpRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "proxy",
+		Name:      "http_request_duration_seconds",
+		Help:      "HTTP request latency by route and status code.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"route", "status"})
+)
+
+// RegisterMetrics registers proxy metrics with a Prometheus registerer.
+func RegisterMetrics(r prometheus.Registerer) error {
+	for _, c := range []prometheus.Collector{
+		tasksCreated,
+		tasksRunning,
+		remoteCalls,
+		remoteCallsInFlight,
+		remoteCallDuration,
+		httpRequestDuration,
+	} {
+		if err := r.Register(c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,72 @@
+package proxy
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-kit/kit/log"
+	"github.com/golang/mock/gomock"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestMetricsRemoteCalls(t *testing.T) {
+	t.Parallel()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	m := NewMockRemoteClient(ctrl)
+	m.EXPECT().Update(gomock.Any(), "metrics:0", "info").Return(nil)
+
+	c := remoteCalls.WithLabelValues("metrics:0", Success)
+	before := testutil.ToFloat64(c)
+
+	task, err := newTask(context.Background(), &TaskConfig{
+		Mode: Sequential,
+		Info: "info",
+	}, m, []string{"metrics:0"}, log.NewNopLogger())
+	if err != nil {
+		panic(err)
+	}
+
+	<-task.done
+
+	if v := testutil.ToFloat64(c) - before; v != 1 {
+		t.Fatal("wrong remote calls", v)
+	}
+}
+
+func TestMetricsHTTPRequestRoute(t *testing.T) {
+	t.Parallel()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	m := NewMockService(ctrl)
+	m.EXPECT().TaskStatus(gomock.Any(), TaskID("metrics")).Return(nil, nil)
+	s := LoggingMiddleware{Inner: NewServer(m), Logger: log.NewNopLogger()}
+
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/task/metrics/status", nil))
+
+	r := prometheus.NewPedanticRegistry()
+	r.MustRegister(httpRequestDuration)
+	mfs, err := r.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, mf := range mfs {
+		for _, m := range mf.GetMetric() {
+			labels := map[string]string{}
+			for _, l := range m.GetLabel() {
+				labels[l.GetName()] = l.GetValue()
+			}
+			if labels["route"] == "/v1/task/{id}/status" && labels["status"] == "404" {
+				return
+			}
+		}
+	}
+	t.Fatal("missing metric", mfs)
+}
@@ -2,19 +2,26 @@ package proxy
 
 import (
 	"net/http"
+	"strconv"
 	"time"
 
 	"github.com/mmatczuk/proxy/log"
 )
 
-// LoggingMiddleware is a HTTP middleware that logs HTTP requests.
+// LoggingMiddleware is a HTTP middleware that logs HTTP requests and records
+// request latency metrics.
 type LoggingMiddleware struct {
 	Inner  http.Handler
 	Logger log.Logger
 }
 
 func (m LoggingMiddleware) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	m.Logger.Log(
+	logger := m.Logger
+	if id := RequestIDFromContext(r.Context()); id != "" {
+		logger = log.With(logger, "request_id", id)
+	}
+
+	log.Debug(logger).Log(
 		"msg", "request",
 		"method", r.Method,
 		"path", r.URL.Path,
@@ -23,10 +30,20 @@ func (m LoggingMiddleware) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	start := time.Now()
 	sw := &statusAwareWriter{ResponseWriter: w}
 	m.Inner.ServeHTTP(sw, r)
+	duration := time.Since(start)
+
+	route, status := sw.route, sw.status
+	if route == "" {
+		route = "unknown"
+	}
+	if status == 0 {
+		status = http.StatusOK
+	}
+	httpRequestDuration.WithLabelValues(route, strconv.Itoa(status)).Observe(duration.Seconds())
 
-	m.Logger.Log(
+	log.Info(logger).Log(
 		"msg", "response",
-		"duration", time.Since(start),
+		"duration", duration,
 		"method", r.Method,
 		"path", r.URL.Path,
 		"status", sw.status,
@@ -34,10 +51,11 @@ func (m LoggingMiddleware) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 }
 
 // statusAwareWriter is a http.ResponseWriter that provides information on
-// status code.
+// status code and matched route.
 type statusAwareWriter struct {
 	http.ResponseWriter
 	status int
+	route  string
 }
 
 func (w *statusAwareWriter) Write(data []byte) (int, error) {
@@ -51,3 +69,10 @@ func (w *statusAwareWriter) WriteHeader(statusCode int) {
 	w.status = statusCode
 	w.ResponseWriter.WriteHeader(statusCode)
 }
+
+// setRoute records route template on statusAwareWriter.
+func setRoute(w http.ResponseWriter, route string) {
+	if sw, ok := w.(*statusAwareWriter); ok {
+		sw.route = route
+	}
+}
@@ -51,13 +51,68 @@ func (_mr *_MockServiceRecorder) TaskStatus(arg0, arg1 interface{}) *gomock.Call
 	return _mr.mock.ctrl.RecordCall(_mr.mock, "TaskStatus", arg0, arg1)
 }
 
-func (_m *MockService) KillTask(ctx context.Context, id TaskID) (*TaskStatus, error) {
-	ret := _m.ctrl.Call(_m, "KillTask", ctx, id)
+func (_m *MockService) KillTask(ctx context.Context, id TaskID, opts KillOptions) (*TaskStatus, error) {
+	ret := _m.ctrl.Call(_m, "KillTask", ctx, id, opts)
 	ret0, _ := ret[0].(*TaskStatus)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-func (_mr *_MockServiceRecorder) KillTask(arg0, arg1 interface{}) *gomock.Call {
-	return _mr.mock.ctrl.RecordCall(_mr.mock, "KillTask", arg0, arg1)
+func (_mr *_MockServiceRecorder) KillTask(arg0, arg1, arg2 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "KillTask", arg0, arg1, arg2)
+}
+
+func (_m *MockService) PauseTask(ctx context.Context, id TaskID) (*TaskStatus, error) {
+	ret := _m.ctrl.Call(_m, "PauseTask", ctx, id)
+	ret0, _ := ret[0].(*TaskStatus)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockServiceRecorder) PauseTask(arg0, arg1 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "PauseTask", arg0, arg1)
+}
+
+func (_m *MockService) ResumeTask(ctx context.Context, id TaskID) (*TaskStatus, error) {
+	ret := _m.ctrl.Call(_m, "ResumeTask", ctx, id)
+	ret0, _ := ret[0].(*TaskStatus)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockServiceRecorder) ResumeTask(arg0, arg1 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "ResumeTask", arg0, arg1)
+}
+
+func (_m *MockService) RetryTask(ctx context.Context, id TaskID, opts RetryOptions) (TaskID, error) {
+	ret := _m.ctrl.Call(_m, "RetryTask", ctx, id, opts)
+	ret0, _ := ret[0].(TaskID)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockServiceRecorder) RetryTask(arg0, arg1, arg2 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "RetryTask", arg0, arg1, arg2)
+}
+
+func (_m *MockService) PlanTask(ctx context.Context, config *TaskConfig) (*Plan, error) {
+	ret := _m.ctrl.Call(_m, "PlanTask", ctx, config)
+	ret0, _ := ret[0].(*Plan)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockServiceRecorder) PlanTask(arg0, arg1 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "PlanTask", arg0, arg1)
+}
+
+func (_m *MockService) ListTasks(ctx context.Context) ([]*TaskStatus, error) {
+	ret := _m.ctrl.Call(_m, "ListTasks", ctx)
+	ret0, _ := ret[0].([]*TaskStatus)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockServiceRecorder) ListTasks(arg0 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "ListTasks", arg0)
 }
@@ -1,5 +1,11 @@
 package proxy
 
+import (
+	"encoding/json"
+	"math"
+	"time"
+)
+
 // TaskID specifies task identifier.
 type TaskID string
 
@@ -10,6 +16,16 @@ type TaskMode string
 const (
 	Sequential TaskMode = "sequential"
 	Parallel            = "parallel"
+	// TwoPhase sends prepare to all addresses and commit only if enough of
+	// them are prepared, otherwise prepared addresses are sent abort.
+	TwoPhase = "two_phase"
+	// Quorum calls all addresses in parallel, the task succeeds when
+	// required number of calls succeed.
+	Quorum = "quorum"
+	// Race calls addresses one at a time until the first success, next
+	// address is called when the previous call fails or does not finish
+	// within hedge delay.
+	Race = "race"
 )
 
 // TaskConfig specifies task parameters when creating new task.
@@ -18,6 +34,107 @@ type TaskConfig struct {
 	Info        string   `json:"info"`
 	Mode        TaskMode `json:"mode"`
 	FailOnError bool     `json:"failonerror"`
+	// NotBefore delays task execution until the given time.
+	NotBefore *time.Time `json:"not_before,omitempty"`
+	// Delay delays task execution by the given duration.
+	Delay Duration `json:"delay,omitempty"`
+	// Schedule is a cron expression, if set a new task is created on every
+	// tick.
+	Schedule string `json:"schedule,omitempty"`
+	// CompensationInfo is sent to addresses that were updated successfully
+	// when task is killed before all calls succeeded or fails with
+	// FailOnError, in reverse order.
+	CompensationInfo string `json:"compensation_info,omitempty"`
+	// PrepareInfo and AbortInfo are sent in prepare and abort phases of
+	// two-phase task, both are required in two-phase mode.
+	PrepareInfo string `json:"prepare_info,omitempty"`
+	AbortInfo   string `json:"abort_info,omitempty"`
+	// Required is the number of prepared addresses required to commit
+	// two-phase task or the number of successful calls of quorum task. When
+	// a task is retried calls that succeeded count towards Required.
+	Required Threshold `json:"required,omitempty"`
+	// CancelRemaining kills calls of quorum task that are still running when
+	// the verdict is known.
+	CancelRemaining bool `json:"cancel_remaining,omitempty"`
+	// HedgeDelay is the time race task waits for a call before calling the
+	// next address, zero means waiting for the call to finish.
+	HedgeDelay Duration `json:"hedge_delay,omitempty"`
+	// DependsOn delays task execution until the given tasks are done, if
+	// they do not meet Condition the task is killed.
+	DependsOn []TaskID `json:"depends_on,omitempty"`
+	// Condition specifies when dependencies are met, default is succeeded.
+	Condition Condition `json:"condition,omitempty"`
+	// Steps are executed in order, every step has its own info, addresses,
+	// mode and failure policy.
+	Steps []Step `json:"steps,omitempty"`
+}
+
+// Step is a stage of a multi-step task.
+type Step struct {
+	Name string `json:"name"`
+	// Info is sent to addresses of the step, it defaults to task info.
+	Info string `json:"info,omitempty"`
+	// Addrs limits the step to the given addresses, if empty all addresses
+	// are called.
+	Addrs []string `json:"addrs,omitempty"`
+	// Mode is sequential or parallel, it defaults to task mode.
+	Mode TaskMode `json:"mode,omitempty"`
+	// FailOnError stops the task if a call of the step fails, task
+	// FailOnError applies to all steps.
+	FailOnError bool `json:"failonerror,omitempty"`
+}
+
+// Condition specifies when dependencies of a task are met.
+type Condition string
+
+// Condition values.
+const (
+	// ConditionSucceeded is met when all dependencies succeeded.
+	ConditionSucceeded Condition = "succeeded"
+	// ConditionFinished is met when all dependencies are done regardless
+	// of the outcome.
+	ConditionFinished Condition = "finished"
+)
+
+// Threshold specifies number of successful calls, values lower than 1 are
+// ratios of all calls, zero means all calls.
+type Threshold float64
+
+func (q Threshold) count(n int) int {
+	switch {
+	case q == 0:
+		return n
+	case q < 1:
+		return int(math.Ceil(float64(q) * float64(n)))
+	default:
+		return int(q)
+	}
+}
+
+func (q Threshold) valid() bool {
+	return q >= 0 && (q < 1 || q == Threshold(math.Trunc(float64(q))))
+}
+
+// Duration is a time.Duration encoded in JSON as a string i.e. "1m30s".
+type Duration time.Duration
+
+// MarshalJSON implements json.Marshaler.
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
 }
 
 // Status specifies remote command execution status.
@@ -25,12 +142,14 @@ type Status string
 
 // Status values.
 const (
-	Pending Status = "pending"
-	Running        = "running"
-	Success        = "success"
-	Failure        = "failure"
-	Killed         = "killed"
-	Ignored        = "ignored"
+	Pending        Status = "pending"
+	Running               = "running"
+	Success               = "success"
+	Failure               = "failure"
+	Killed                = "killed"
+	Ignored               = "ignored"
+	RolledBack            = "rolled_back"
+	RollbackFailed        = "rollback_failed"
 )
 
 // Result represents remote command execution result.
@@ -38,9 +157,108 @@ type Result struct {
 	Addr   string `json:"addr"`
 	Status Status `json:"status"`
 	Msg    string `json:"message,omitempty"`
+	// KillReason is the reason given when the call was killed or ignored
+	// due to kill.
+	KillReason string `json:"kill_reason,omitempty"`
+	// Phase is the last phase the call reached in two-phase task.
+	Phase Phase `json:"phase,omitempty"`
+	// Step is name of the step of multi-step task the call belongs to.
+	Step string `json:"step,omitempty"`
+}
+
+// TaskState specifies overall task state.
+type TaskState string
+
+// TaskState values.
+const (
+	StateWaiting   TaskState = "waiting"
+	StateScheduled TaskState = "scheduled"
+	StateRunning   TaskState = "running"
+	StatePaused    TaskState = "paused"
+	StateDone      TaskState = "done"
+)
+
+// Verdict specifies overall outcome of a task.
+type Verdict string
+
+// Verdict values.
+const (
+	VerdictSucceeded Verdict = "succeeded"
+	VerdictFailed    Verdict = "failed"
+)
+
+// KillOptions specifies how a task is killed.
+type KillOptions struct {
+	// Reason is recorded in task status and in affected results.
+	Reason string
+	// Addrs limits kill to remote calls to the given addresses, if empty the
+	// whole task is killed.
+	Addrs []string
+	// Async makes KillTask return without waiting for the killed calls to
+	// finish.
+	Async bool
+	// Cascade kills also tasks depending on the task, recursively.
+	Cascade bool
+}
+
+// Plan describes remote calls a task would make without making them.
+type Plan struct {
+	Mode        TaskMode `json:"mode"`
+	FailOnError bool     `json:"failonerror"`
+	// StartAt is the time of the first execution of a delayed or recurring
+	// task.
+	StartAt *time.Time `json:"start_at,omitempty"`
+	// Schedule is a cron expression of a recurring task.
+	Schedule string `json:"schedule,omitempty"`
+	// Steps are executed in order, calls within a step concurrently.
+	Steps []PlanStep `json:"steps"`
+}
+
+// PlanStep is a group of remote calls executed concurrently.
+type PlanStep struct {
+	Calls []PlanCall `json:"calls"`
+}
+
+// PlanCall is a planned remote call.
+type PlanCall struct {
+	Addr  string `json:"addr"`
+	Info  string `json:"info"`
+	Phase Phase  `json:"phase,omitempty"`
+	Step  string `json:"step,omitempty"`
+}
+
+// RetryOptions specifies which remote calls of a task are retried.
+type RetryOptions struct {
+	// Statuses selects calls to retry by their status, if empty calls that
+	// failed, were killed or ignored are retried.
+	Statuses []Status
 }
 
 // TaskStatus represents overall task status.
 type TaskStatus struct {
-	Results []Result `json:"results"` // enforce copy when returning status
+	ID       TaskID    `json:"id"`
+	ClientID string    `json:"client_id,omitempty"`
+	State    TaskState `json:"state"`
+	// RequestID is identifier of the request that created the task.
+	RequestID string `json:"request_id,omitempty"`
+	// ParentID is identifier of the task retried by this task.
+	ParentID TaskID `json:"parent_id,omitempty"`
+	// DependsOn contains identifiers of tasks this task waits for.
+	DependsOn []TaskID `json:"depends_on,omitempty"`
+	// KillReason is the reason given when the task was killed.
+	KillReason string `json:"kill_reason,omitempty"`
+	// Verdict is overall outcome of quorum or race task, it's set as soon
+	// as it's known.
+	Verdict Verdict `json:"verdict,omitempty"`
+	// Step is name of the current or, when task is done, the last started
+	// step of multi-step task.
+	Step string `json:"step,omitempty"`
+	// StartAt is the time of the next execution of a scheduled task.
+	StartAt *time.Time `json:"start_at,omitempty"`
+	// Schedule is a cron expression of a recurring task.
+	Schedule string `json:"schedule,omitempty"`
+	// Children contains identifiers of tasks spawned by a recurring task or
+	// retrying this task.
+	Children []TaskID `json:"children,omitempty"`
+	Results  []Result `json:"results"` // enforce copy when returning status
 }
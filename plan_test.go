@@ -0,0 +1,66 @@
+package proxy
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/golang/mock/gomock"
+)
+
+func TestServicePlanTask(t *testing.T) {
+	t.Parallel()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	s := NewService(NewMockRemoteClient(ctrl), []string{"addr0", "addr1"}, log.NewNopLogger())
+	ctx := context.Background()
+
+	table := []struct {
+		Mode  TaskMode
+		Steps []PlanStep
+	}{
+		{
+			Mode: Sequential,
+			Steps: []PlanStep{
+				{Calls: []PlanCall{{Addr: "addr0", Info: "info"}}},
+				{Calls: []PlanCall{{Addr: "addr1", Info: "info"}}},
+			},
+		},
+		{
+			Mode: Parallel,
+			Steps: []PlanStep{
+				{Calls: []PlanCall{{Addr: "addr0", Info: "info"}, {Addr: "addr1", Info: "info"}}},
+			},
+		},
+	}
+
+	for _, test := range table {
+		p, err := s.PlanTask(ctx, &TaskConfig{Mode: test.Mode, Info: "info", FailOnError: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(p, &Plan{Mode: test.Mode, FailOnError: true, Steps: test.Steps}) {
+			t.Fatal("wrong plan", p)
+		}
+	}
+
+	p, err := s.PlanTask(ctx, &TaskConfig{Mode: Sequential, Info: "info", Delay: Duration(time.Hour)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.StartAt == nil || time.Until(*p.StartAt) < 59*time.Minute {
+		t.Fatal("wrong start time", p)
+	}
+
+	if _, err := s.PlanTask(ctx, &TaskConfig{Mode: "foo"}); err == nil {
+		t.Fatal("expected error")
+	}
+
+	l, _ := s.ListTasks(ctx)
+	if len(l) != 0 {
+		t.Fatal("unexpected tasks", l)
+	}
+}
@@ -0,0 +1,132 @@
+package proxy
+
+import (
+	"fmt"
+	"os"
+)
+
+// Permission specifies operation that caller may perform.
+type Permission string
+
+// Permission values.
+const (
+	PermissionCreate Permission = "create"
+	PermissionStatus Permission = "status"
+	PermissionKill   Permission = "kill"
+	// PermissionAudit allows reading audit log, it's not granted by
+	// default.
+	PermissionAudit Permission = "audit"
+)
+
+// Role is a named set of permissions.
+type Role struct {
+	Permissions []Permission `json:"permissions"`
+	// AllTasks grants access to tasks of all clients, otherwise only tasks
+	// created by the client are accessible.
+	AllTasks bool `json:"all_tasks"`
+}
+
+// Policy assigns roles to clients.
+type Policy struct {
+	Roles map[string]Role `json:"roles"`
+	// Clients maps client ID to role name.
+	Clients map[string]string `json:"clients"`
+	// DefaultRole is a role of clients not listed in Clients, if empty such
+	// clients are denied access.
+	DefaultRole string `json:"default_role"`
+}
+
+// defaultRole is used when no policy is configured, it grants all
+// permissions on own tasks.
+var defaultRole = Role{
+	Permissions: []Permission{PermissionCreate, PermissionStatus, PermissionKill},
+}
+
+// LoadPolicy reads policy from JSON file.
+func LoadPolicy(file string) (*Policy, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var p Policy
+	if err := readJSON(&p, f); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %s", file, err)
+	}
+
+	if err := p.validate(); err != nil {
+		return nil, err
+	}
+
+	return &p, nil
+}
+
+func (p *Policy) validate() error {
+	for name, r := range p.Roles {
+		for _, v := range r.Permissions {
+			switch v {
+			case PermissionCreate, PermissionStatus, PermissionKill, PermissionAudit:
+			default:
+				return fmt.Errorf("role %s: unknown permission %q", name, v)
+			}
+		}
+	}
+	for client, role := range p.Clients {
+		if _, ok := p.Roles[role]; !ok {
+			return fmt.Errorf("client %s: unknown role %q", client, role)
+		}
+	}
+	if p.DefaultRole != "" {
+		if _, ok := p.Roles[p.DefaultRole]; !ok {
+			return fmt.Errorf("unknown default role %q", p.DefaultRole)
+		}
+	}
+	return nil
+}
+
+// role returns role name and role of a client, nil policy grants all
+// permissions on own tasks.
+func (p *Policy) role(clientID string) (string, *Role) {
+	if p == nil {
+		return "", &defaultRole
+	}
+
+	name, ok := p.Clients[clientID]
+	if !ok {
+		name = p.DefaultRole
+	}
+	r, ok := p.Roles[name]
+	if !ok {
+		return "", nil
+	}
+	return name, &r
+}
+
+// Authorize assigns role and permissions to identity, it returns false if
+// the client has no role. Nil policy grants all permissions on own tasks.
+func (p *Policy) Authorize(id *Identity) bool {
+	name, role := p.role(id.ClientID)
+	if role == nil {
+		return false
+	}
+	id.Role = name
+	id.Permissions = role.Permissions
+	id.AllTasks = role.AllTasks
+	return true
+}
+
+// Can returns true if identity has permission p.
+func (id *Identity) Can(p Permission) bool {
+	for _, v := range id.Permissions {
+		if v == p {
+			return true
+		}
+	}
+	return false
+}
+
+// Owns returns true if identity has access to tasks of the client.
+func (id *Identity) Owns(clientID string) bool {
+	return id.ClientID == clientID || id.AllTasks
+}
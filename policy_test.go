@@ -0,0 +1,138 @@
+package proxy
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-kit/kit/log"
+	"github.com/golang/mock/gomock"
+)
+
+func TestServiceTaskOwnership(t *testing.T) {
+	t.Parallel()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	m := NewMockRemoteClient(ctrl)
+	m.EXPECT().Update(gomock.Any(), "addr0", "info").Return(nil)
+
+	s := NewService(m, []string{"addr0"}, log.NewNopLogger())
+
+	owner := WithIdentity(context.Background(), &Identity{
+		ClientID:    "client:1",
+		Permissions: []Permission{PermissionCreate, PermissionStatus, PermissionKill},
+	})
+	other := WithIdentity(context.Background(), &Identity{
+		ClientID:    "client:2",
+		Permissions: []Permission{PermissionCreate, PermissionStatus, PermissionKill},
+	})
+	admin := WithIdentity(context.Background(), &Identity{
+		ClientID:    "admin",
+		Permissions: []Permission{PermissionStatus, PermissionKill},
+		AllTasks:    true,
+	})
+	viewer := WithIdentity(context.Background(), &Identity{
+		ClientID:    "client:1",
+		Permissions: []Permission{PermissionStatus},
+	})
+
+	id, err := s.CreateTask(owner, &TaskConfig{Mode: Sequential, Info: "info"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if st, err := s.TaskStatus(owner, id); err != nil || st == nil || st.ClientID != "client:1" {
+		t.Fatal("owner cannot see task", st, err)
+	}
+	if st, err := s.TaskStatus(other, id); err != nil || st != nil {
+		t.Fatal("other client can see task", st, err)
+	}
+	if st, err := s.KillTask(other, id, KillOptions{}); err != nil || st != nil {
+		t.Fatal("other client can kill task", st, err)
+	}
+	if l, err := s.ListTasks(other); err != nil || len(l) != 0 {
+		t.Fatal("other client can list task", l, err)
+	}
+	if st, err := s.TaskStatus(admin, id); err != nil || st == nil {
+		t.Fatal("admin cannot see task", st, err)
+	}
+	if st, err := s.TaskStatus(viewer, id); err != nil || st == nil {
+		t.Fatal("viewer cannot see task", st, err)
+	}
+	if _, err := s.KillTask(viewer, id, KillOptions{}); err == nil {
+		t.Fatal("viewer can kill task")
+	} else if _, ok := err.(*PermissionError); !ok {
+		t.Fatal("expected permission error", err)
+	}
+	if _, err := s.CreateTask(viewer, &TaskConfig{Mode: Sequential, Info: "info"}); err == nil {
+		t.Fatal("viewer can create task")
+	}
+	if st, err := s.KillTask(admin, id, KillOptions{}); err != nil || st == nil {
+		t.Fatal("admin cannot kill task", st, err)
+	}
+}
+
+func TestAuthMiddlewarePolicy(t *testing.T) {
+	t.Parallel()
+
+	p := &Policy{
+		Roles: map[string]Role{
+			"admin":  {Permissions: []Permission{PermissionStatus, PermissionKill}, AllTasks: true},
+			"viewer": {Permissions: []Permission{PermissionStatus}},
+		},
+		Clients: map[string]string{
+			"client:key": "admin",
+		},
+	}
+	if err := p.validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	var id *Identity
+	m := AuthMiddleware{
+		Inner: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			id = IdentityFromContext(r.Context())
+		}),
+		Auth:   testAuthenticator(t),
+		Policy: p,
+	}
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set(APIKeyHeader, "secret")
+	w := httptest.NewRecorder()
+	m.ServeHTTP(w, r)
+
+	if w.Code != http.StatusOK || id.Role != "admin" || !id.AllTasks || !id.Can(PermissionKill) || id.Can(PermissionCreate) {
+		t.Fatal("wrong identity", w.Code, id)
+	}
+
+	p.Clients = nil
+	w = httptest.NewRecorder()
+	m.ServeHTTP(w, r)
+	if w.Code != http.StatusForbidden {
+		t.Fatal("wrong status code", w.Code)
+	}
+
+	p.DefaultRole = "viewer"
+	w = httptest.NewRecorder()
+	m.ServeHTTP(w, r)
+	if w.Code != http.StatusOK || id.Role != "viewer" {
+		t.Fatal("wrong identity", w.Code, id)
+	}
+}
+
+func TestPolicyValidate(t *testing.T) {
+	t.Parallel()
+
+	for _, p := range []*Policy{
+		{Roles: map[string]Role{"r": {Permissions: []Permission{"delete"}}}},
+		{Clients: map[string]string{"c": "r"}},
+		{DefaultRole: "r"},
+	} {
+		if err := p.validate(); err == nil {
+			t.Error("expected error", p)
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package proxy
+
+import (
+	"context"
+	"sync"
+
+	"github.com/mmatczuk/proxy/log"
+)
+
+// runQuorum calls all addresses in parallel, verdict is set when enough
+// calls succeed or when it's no longer possible. If config requests it calls
+// still running are killed then.
+func (t *task) runQuorum(ctx context.Context, config *TaskConfig) {
+	var (
+		n         = len(t.results)
+		required  = config.Required.count(n)
+		wg        sync.WaitGroup
+		mu        sync.Mutex
+		succeeded int
+		failed    int
+	)
+
+	for _, r := range t.results {
+		r := r
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			t.remoteCall(ctx, config, r.Addr, r)
+
+			mu.Lock()
+			if r.status() == Success {
+				succeeded++
+			} else {
+				failed++
+			}
+			var v Verdict
+			switch {
+			case succeeded == required:
+				v = VerdictSucceeded
+			case failed == n-required+1:
+				v = VerdictFailed
+			}
+			mu.Unlock()
+
+			if v != "" {
+				t.decide(v, config.CancelRemaining)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// decide sets task verdict and optionally kills running calls.
+func (t *task) decide(v Verdict, cancelRemaining bool) {
+	t.setVerdict(v)
+
+	log.Info(t.logger).Log(
+		"msg", "quorum verdict",
+		"verdict", v,
+	)
+
+	if !cancelRemaining {
+		return
+	}
+	reason := "quorum " + string(v)
+	for _, r := range t.results {
+		r.kill(reason)
+	}
+}
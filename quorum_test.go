@@ -0,0 +1,149 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/golang/mock/gomock"
+)
+
+func TestRunQuorumTaskSucceeded(t *testing.T) {
+	t.Parallel()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	started := make(chan struct{})
+	wait := func(ctx context.Context, addr, info string) { <-started }
+	m := NewMockRemoteClient(ctrl)
+	m.EXPECT().Update(gomock.Any(), "addr0", "info").Return(nil).Do(wait)
+	m.EXPECT().Update(gomock.Any(), "addr1", "info").Return(nil).Do(wait)
+	m.EXPECT().Update(gomock.Any(), "addr2", "info").Return(context.Canceled).Do(func(ctx context.Context, addr, info string) {
+		close(started)
+		<-ctx.Done()
+	})
+
+	task, err := newTask(context.Background(), &TaskConfig{
+		Mode:            Quorum,
+		Info:            "info",
+		Required:        2,
+		CancelRemaining: true,
+	}, m, []string{"addr0", "addr1", "addr2"}, log.NewNopLogger())
+	if err != nil {
+		panic(err)
+	}
+
+	<-task.done
+
+	s := task.status()
+
+	if !reflect.DeepEqual(s, &TaskStatus{
+		ID:      task.ID(),
+		State:   StateDone,
+		Verdict: VerdictSucceeded,
+		Results: []Result{
+			{
+				Addr:   "addr0",
+				Status: Success,
+			},
+			{
+				Addr:   "addr1",
+				Status: Success,
+			},
+			{
+				Addr:       "addr2",
+				Status:     Killed,
+				KillReason: "quorum succeeded",
+			},
+		},
+	}) {
+		t.Fatal("wrong status", s)
+	}
+}
+
+func TestRunQuorumTaskFailed(t *testing.T) {
+	t.Parallel()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	release := make(chan struct{})
+	m := NewMockRemoteClient(ctrl)
+	m.EXPECT().Update(gomock.Any(), "addr0", "info").Return(errors.New("boom"))
+	m.EXPECT().Update(gomock.Any(), "addr1", "info").Return(errors.New("boom"))
+	m.EXPECT().Update(gomock.Any(), "addr2", "info").Return(nil).Do(func(ctx context.Context, addr, info string) {
+		<-release
+	})
+
+	task, err := newTask(context.Background(), &TaskConfig{
+		Mode:     Quorum,
+		Info:     "info",
+		Required: 0.5,
+	}, m, []string{"addr0", "addr1", "addr2"}, log.NewNopLogger())
+	if err != nil {
+		panic(err)
+	}
+
+	// verdict is known before the task is done
+	<-task.results[0].finished
+	<-task.results[1].finished
+	for task.status().Verdict == "" {
+		time.Sleep(time.Millisecond)
+	}
+	if s := task.status(); s.Verdict != VerdictFailed || s.State != StateRunning {
+		t.Fatal("wrong status", s)
+	}
+
+	close(release)
+	<-task.done
+
+	s := task.status()
+
+	if s.Verdict != VerdictFailed || s.Results[2].Status != Success {
+		t.Fatal("wrong status", s)
+	}
+}
+
+func TestServiceRetryQuorumTask(t *testing.T) {
+	t.Parallel()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	m := NewMockRemoteClient(ctrl)
+	m.EXPECT().Update(gomock.Any(), "addr0", "info").Return(nil)
+	m.EXPECT().Update(gomock.Any(), "addr1", "info").Return(nil)
+	gomock.InOrder(
+		m.EXPECT().Update(gomock.Any(), "addr2", "info").Return(errors.New("boom")),
+		m.EXPECT().Update(gomock.Any(), "addr2", "info").Return(nil),
+	)
+	gomock.InOrder(
+		m.EXPECT().Update(gomock.Any(), "addr3", "info").Return(errors.New("boom")),
+		m.EXPECT().Update(gomock.Any(), "addr3", "info").Return(errors.New("boom")),
+	)
+
+	s := NewService(m, []string{"addr0", "addr1", "addr2", "addr3"}, log.NewNopLogger()).(*service)
+	ctx := context.Background()
+
+	id, err := s.CreateTask(ctx, &TaskConfig{Mode: Quorum, Info: "info", Required: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-s.tasks[id].done
+
+	if st, _ := s.TaskStatus(ctx, id); st.Verdict != VerdictFailed {
+		t.Fatal("wrong verdict", st)
+	}
+
+	child, err := s.RetryTask(ctx, id, RetryOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-s.tasks[child].done
+
+	st, _ := s.TaskStatus(ctx, child)
+	if st.Verdict != VerdictSucceeded || len(st.Results) != 2 {
+		t.Fatal("wrong status", st)
+	}
+}
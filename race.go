@@ -0,0 +1,93 @@
+package proxy
+
+import (
+	"context"
+	"time"
+
+	"github.com/mmatczuk/proxy/log"
+)
+
+// runRace calls addresses one at a time, next address is called when the
+// previous call fails or does not finish within hedge delay. On the first
+// success calls still running are killed and pending calls are ignored.
+func (t *task) runRace(ctx context.Context, config *TaskConfig) {
+	var (
+		delay    = time.Duration(config.HedgeDelay)
+		finished = make(chan *result, len(t.results))
+		next     int
+		inFlight int
+		winner   *result
+	)
+
+	hedge := time.NewTimer(0)
+	if !hedge.Stop() {
+		<-hedge.C
+	}
+	defer hedge.Stop()
+
+	dispatch := func() {
+		r := t.results[next]
+		next++
+		inFlight++
+		go func() {
+			t.remoteCall(ctx, config, r.Addr, r)
+			finished <- r
+		}()
+		if delay > 0 && next < len(t.results) {
+			hedge.Reset(delay)
+		}
+	}
+
+	dispatch()
+	for inFlight > 0 {
+		select {
+		case r := <-finished:
+			inFlight--
+			if winner != nil || t.killed() {
+				continue
+			}
+			if r.status() == Success {
+				winner = r
+				t.win(r)
+				continue
+			}
+			if next < len(t.results) {
+				if !hedge.Stop() && delay > 0 {
+					select {
+					case <-hedge.C:
+					default:
+					}
+				}
+				dispatch()
+			}
+		case <-hedge.C:
+			if winner == nil && !t.killed() && next < len(t.results) {
+				dispatch()
+			}
+		}
+	}
+
+	if winner == nil {
+		t.markPendingIgnored(t.reason())
+		t.setVerdict(VerdictFailed)
+	}
+}
+
+// win sets verdict of race task won by r, calls to other addresses are
+// killed or ignored.
+func (t *task) win(r *result) {
+	t.setVerdict(VerdictSucceeded)
+
+	log.Info(t.logger).Log(
+		"msg", "race won",
+		"addr", r.Addr,
+	)
+
+	reason := "race won by " + r.Addr
+	t.markPendingIgnored(reason)
+	for _, v := range t.results {
+		if v != r {
+			v.kill(reason)
+		}
+	}
+}
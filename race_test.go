@@ -0,0 +1,136 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/golang/mock/gomock"
+)
+
+func TestRunRaceTaskFailover(t *testing.T) {
+	t.Parallel()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	m := NewMockRemoteClient(ctrl)
+	gomock.InOrder(
+		m.EXPECT().Update(gomock.Any(), "addr0", "info").Return(errors.New("boom")),
+		m.EXPECT().Update(gomock.Any(), "addr1", "info").Return(nil),
+	)
+
+	task, err := newTask(context.Background(), &TaskConfig{
+		Mode: Race,
+		Info: "info",
+	}, m, []string{"addr0", "addr1", "addr2"}, log.NewNopLogger())
+	if err != nil {
+		panic(err)
+	}
+
+	<-task.done
+
+	s := task.status()
+
+	if !reflect.DeepEqual(s, &TaskStatus{
+		ID:      task.ID(),
+		State:   StateDone,
+		Verdict: VerdictSucceeded,
+		Results: []Result{
+			{
+				Addr:   "addr0",
+				Status: Failure,
+				Msg:    "boom",
+			},
+			{
+				Addr:   "addr1",
+				Status: Success,
+			},
+			{
+				Addr:       "addr2",
+				Status:     Ignored,
+				KillReason: "race won by addr1",
+			},
+		},
+	}) {
+		t.Fatal("wrong status", s)
+	}
+}
+
+func TestRunRaceTaskHedge(t *testing.T) {
+	t.Parallel()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	m := NewMockRemoteClient(ctrl)
+	m.EXPECT().Update(gomock.Any(), "addr0", "info").Return(context.Canceled).Do(func(ctx context.Context, addr, info string) {
+		<-ctx.Done()
+	})
+	m.EXPECT().Update(gomock.Any(), "addr1", "info").Return(nil)
+
+	task, err := newTask(context.Background(), &TaskConfig{
+		Mode:       Race,
+		Info:       "info",
+		HedgeDelay: Duration(10 * time.Millisecond),
+	}, m, []string{"addr0", "addr1", "addr2"}, log.NewNopLogger())
+	if err != nil {
+		panic(err)
+	}
+
+	<-task.done
+
+	s := task.status()
+
+	if !reflect.DeepEqual(s, &TaskStatus{
+		ID:      task.ID(),
+		State:   StateDone,
+		Verdict: VerdictSucceeded,
+		Results: []Result{
+			{
+				Addr:       "addr0",
+				Status:     Killed,
+				KillReason: "race won by addr1",
+			},
+			{
+				Addr:   "addr1",
+				Status: Success,
+			},
+			{
+				Addr:       "addr2",
+				Status:     Ignored,
+				KillReason: "race won by addr1",
+			},
+		},
+	}) {
+		t.Fatal("wrong status", s)
+	}
+}
+
+func TestRunRaceTaskFailed(t *testing.T) {
+	t.Parallel()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	m := NewMockRemoteClient(ctrl)
+	m.EXPECT().Update(gomock.Any(), "addr0", "info").Return(errors.New("boom"))
+	m.EXPECT().Update(gomock.Any(), "addr1", "info").Return(errors.New("boom"))
+
+	task, err := newTask(context.Background(), &TaskConfig{
+		Mode:       Race,
+		Info:       "info",
+		HedgeDelay: Duration(time.Millisecond),
+	}, m, []string{"addr0", "addr1"}, log.NewNopLogger())
+	if err != nil {
+		panic(err)
+	}
+
+	<-task.done
+
+	s := task.status()
+
+	if s.Verdict != VerdictFailed || s.Results[0].Status != Failure || s.Results[1].Status != Failure {
+		t.Fatal("wrong status", s)
+	}
+}
@@ -1,7 +1,9 @@
 package proxy
 
 import (
+	"bytes"
 	"context"
+	"crypto/tls"
 	"fmt"
 	"io"
 	"io/ioutil"
@@ -10,6 +12,8 @@ import (
 	"net/url"
 	"strings"
 	"time"
+
+	"github.com/mmatczuk/proxy/trace"
 )
 
 // RemoteClient provides ability to call the legacy system, implementations must
@@ -19,45 +23,182 @@ type RemoteClient interface {
 }
 
 type remoteClient struct {
-	url    url.URL
 	client http.Client
+	// clients contains clients with backend specific TLS configuration.
+	clients map[string]*http.Client
+	// profiles contains backend specific request profiles.
+	profiles map[string]*RequestProfile
+	// backends contains clients of backends that do not speak HTTP.
+	backends map[string]RemoteClient
+}
+
+// RequestProfile specifies how requests to a backend are built.
+type RequestProfile struct {
+	// Method is HTTP method, default is POST.
+	Method string
+	// Path replaces URL path of the backend.
+	Path string
+	// Header is added to every request.
+	Header http.Header
+	// Username and Password are basic authentication credentials, basic
+	// authentication is used if Username is set.
+	Username string
+	Password string
+}
+
+// RemoteClientOption configures HTTP based remote client.
+type RemoteClientOption func(c *remoteClient)
+
+// WithTLSConfig sets TLS configuration used to call all HTTPS backends
+// without backend specific configuration.
+func WithTLSConfig(config *tls.Config) RemoteClientOption {
+	return func(c *remoteClient) {
+		c.client.Transport = newTransport(config)
+	}
+}
+
+// WithBackendTLSConfig sets TLS configuration used to call backend addr, if
+// addr is host:port it's called over HTTPS.
+func WithBackendTLSConfig(addr string, config *tls.Config) RemoteClientOption {
+	return func(c *remoteClient) {
+		c.clients[addr] = &http.Client{
+			Transport: newTransport(config),
+		}
+	}
+}
+
+// WithRequestProfile sets request profile used to call backend addr.
+func WithRequestProfile(addr string, p *RequestProfile) RemoteClientOption {
+	return func(c *remoteClient) {
+		c.profiles[addr] = p
+	}
+}
+
+// WithBackendClient makes client c handle calls to backend addr, it is used
+// for backends that do not speak HTTP.
+func WithBackendClient(addr string, c RemoteClient) RemoteClientOption {
+	return func(rc *remoteClient) {
+		rc.backends[addr] = c
+	}
 }
 
-// NewRemoteClient creates instance of HTTP based remote client.
-func NewRemoteClient() RemoteClient {
-	return &remoteClient{
+// NewRemoteClient creates instance of HTTP based remote client. Addresses
+// passed to Update can be either host:port or full URLs, host:port is called
+// over plain HTTP unless backend specific TLS configuration is set.
+func NewRemoteClient(opts ...RemoteClientOption) RemoteClient {
+	c := &remoteClient{
 		client: http.Client{
-			Transport: &http.Transport{
-				Dial: func(network, addr string) (conn net.Conn, err error) {
-					conn, err = net.Dial(network, addr)
-					if conn != nil {
-						err = conn.SetDeadline(time.Time{})
-					}
-					return
-				},
-			},
+			Transport: newTransport(nil),
+		},
+		clients:  make(map[string]*http.Client),
+		profiles: make(map[string]*RequestProfile),
+		backends: make(map[string]RemoteClient),
+	}
+
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	return c
+}
+
+func newTransport(config *tls.Config) *http.Transport {
+	return &http.Transport{
+		Dial: func(network, addr string) (conn net.Conn, err error) {
+			conn, err = net.Dial(network, addr)
+			if conn != nil {
+				err = conn.SetDeadline(time.Time{})
+			}
+			return
 		},
+		TLSClientConfig: config,
+	}
+}
+
+// backendURL returns URL of backend addr, host:port is called over HTTPS if
+// secure is set.
+func backendURL(addr string, secure bool) (*url.URL, error) {
+	if !strings.Contains(addr, "://") {
+		scheme := "http"
+		if secure {
+			scheme = "https"
+		}
+		return &url.URL{Scheme: scheme, Host: addr}, nil
+	}
+
+	u, err := url.Parse(addr)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("unsupported scheme %q", u.Scheme)
+	}
+
+	return u, nil
+}
+
+func (c *remoteClient) httpClient(addr string) *http.Client {
+	if hc, ok := c.clients[addr]; ok {
+		return hc
 	}
+	return &c.client
 }
 
 func (c *remoteClient) Update(ctx context.Context, addr, info string) error {
-	req, err := http.NewRequest(http.MethodPost, fmt.Sprintf("http://%s", addr), strings.NewReader(info))
+	if bc, ok := c.backends[addr]; ok {
+		return bc.Update(ctx, addr, info)
+	}
+
+	_, secure := c.clients[addr]
+	u, err := backendURL(addr, secure)
+	if err != nil {
+		return fmt.Errorf("invalid address: %s", err)
+	}
+
+	method := http.MethodPost
+	p := c.profiles[addr]
+	if p != nil {
+		if p.Method != "" {
+			method = p.Method
+		}
+		if p.Path != "" {
+			u.Path = p.Path
+		}
+	}
+
+	req, err := http.NewRequest(method, u.String(), strings.NewReader(info))
 	if err != nil {
 		return fmt.Errorf("failed to create request: %s", err)
 	}
 	req = req.WithContext(ctx)
+	if p != nil {
+		for k, v := range p.Header {
+			req.Header[k] = v
+		}
+		if p.Username != "" {
+			req.SetBasicAuth(p.Username, p.Password)
+		}
+	}
+	trace.Inject(ctx, req.Header)
+	if id := RequestIDFromContext(ctx); id != "" {
+		req.Header.Set(RequestIDHeader, id)
+	}
+	if p := PhaseFromContext(ctx); p != "" {
+		req.Header.Set(PhaseHeader, string(p))
+	}
 
-	resp, err := c.client.Do(req)
+	resp, err := c.httpClient(addr).Do(req)
 	if err != nil {
 		return fmt.Errorf("failed to send request: %s", err)
 	}
+	defer resp.Body.Close()
 
 	b, err := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
 	if err != nil {
 		return fmt.Errorf("failed to read response: %s", err)
 	}
 
-	if string(b[0:2]) != "OK" {
+	if !bytes.HasPrefix(b, []byte("OK")) {
 		return fmt.Errorf("remote failure: %s", b)
 	}
 
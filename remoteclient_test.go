@@ -2,9 +2,13 @@ package proxy
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"net/http"
 	"net/http/httptest"
 	"testing"
+
+	"github.com/mmatczuk/proxy/trace"
 )
 
 func TestRemoteClientOK(t *testing.T) {
@@ -27,6 +31,171 @@ func TestRemoteClientOK(t *testing.T) {
 	}
 }
 
+func TestRemoteClientURL(t *testing.T) {
+	t.Parallel()
+
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/legacy/update" || r.URL.Query().Get("force") != "1" {
+			t.Error("wrong URL", r.URL)
+		}
+		w.Write([]byte("OK"))
+	}))
+	defer s.Close()
+
+	c := NewRemoteClient()
+
+	err := c.Update(context.Background(), s.URL+"/legacy/update?force=1", "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRemoteClientTLS(t *testing.T) {
+	t.Parallel()
+
+	s := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("OK"))
+	}))
+	defer s.Close()
+
+	pool := x509.NewCertPool()
+	pool.AddCert(s.Certificate())
+
+	// unknown authority
+	if err := NewRemoteClient().Update(context.Background(), s.URL, "test"); err == nil {
+		t.Fatal("expected error")
+	}
+
+	c := NewRemoteClient(WithBackendTLSConfig(s.URL, &tls.Config{RootCAs: pool}))
+	if err := c.Update(context.Background(), s.URL, "test"); err != nil {
+		t.Fatal(err)
+	}
+
+	c = NewRemoteClient(WithTLSConfig(&tls.Config{InsecureSkipVerify: true}))
+	if err := c.Update(context.Background(), s.URL, "test"); err != nil {
+		t.Fatal(err)
+	}
+
+	// host:port with backend specific configuration is called over HTTPS
+	addr := s.Listener.Addr().String()
+	c = NewRemoteClient(WithBackendTLSConfig(addr, &tls.Config{RootCAs: pool}))
+	if err := c.Update(context.Background(), addr, "test"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRemoteClientShortResponse(t *testing.T) {
+	t.Parallel()
+
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer s.Close()
+
+	c := NewRemoteClient()
+	addr := s.Listener.Addr().String()
+
+	err := c.Update(context.Background(), addr, "test")
+	if err == nil || err.Error() != "remote failure: " {
+		t.Fatal(err)
+	}
+}
+
+func TestRemoteClientTraceparent(t *testing.T) {
+	t.Parallel()
+
+	ctx, span := trace.Start(context.Background(), "test")
+
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get(trace.TraceparentHeader) != trace.FormatTraceparent(span.SpanContext()) {
+			t.Error("wrong traceparent", r.Header)
+		}
+		w.Write([]byte("OK"))
+	}))
+	defer s.Close()
+
+	c := NewRemoteClient()
+	addr := s.Listener.Addr().String()
+
+	err := c.Update(ctx, addr, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRemoteClientRequestID(t *testing.T) {
+	t.Parallel()
+
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get(RequestIDHeader) != "test-id" {
+			t.Error("wrong request id", r.Header)
+		}
+		w.Write([]byte("OK"))
+	}))
+	defer s.Close()
+
+	c := NewRemoteClient()
+	addr := s.Listener.Addr().String()
+
+	err := c.Update(WithRequestID(context.Background(), "test-id"), addr, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRemoteClientPhase(t *testing.T) {
+	t.Parallel()
+
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get(PhaseHeader) != "prepare" {
+			t.Error("wrong phase", r.Header)
+		}
+		w.Write([]byte("OK"))
+	}))
+	defer s.Close()
+
+	c := NewRemoteClient()
+	addr := s.Listener.Addr().String()
+
+	err := c.Update(WithPhase(context.Background(), PhasePrepare), addr, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRemoteClientRequestProfile(t *testing.T) {
+	t.Parallel()
+
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPut {
+			t.Error("wrong method", r.Method)
+		}
+		if r.URL.Path != "/api/update" {
+			t.Error("wrong path", r.URL.Path)
+		}
+		if r.Header.Get("X-Legacy") != "1" {
+			t.Error("missing header", r.Header)
+		}
+		if u, p, ok := r.BasicAuth(); !ok || u != "user" || p != "pass" {
+			t.Error("wrong basic auth", u, p)
+		}
+		w.Write([]byte("OK"))
+	}))
+	defer s.Close()
+
+	addr := s.Listener.Addr().String()
+	c := NewRemoteClient(WithRequestProfile(addr, &RequestProfile{
+		Method:   http.MethodPut,
+		Path:     "/api/update",
+		Header:   http.Header{"X-Legacy": []string{"1"}},
+		Username: "user",
+		Password: "pass",
+	}))
+
+	err := c.Update(context.Background(), addr, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
 func TestRemoteClientError(t *testing.T) {
 	t.Parallel()
 
@@ -64,7 +233,7 @@ func TestRemoteClientCancel(t *testing.T) {
 
 		err := c.Update(ctx, addr, "test")
 		if err == nil {
-			t.Fatal(err)
+			t.Error("expected error")
 		}
 	}()
 
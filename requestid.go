@@ -0,0 +1,64 @@
+package proxy
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/google/uuid"
+)
+
+// RequestIDHeader is the HTTP header carrying request identifier.
+const RequestIDHeader = "X-Request-ID"
+
+// maxRequestIDLen is maximal length of request identifier accepted from
+// clients, longer identifiers are replaced.
+const maxRequestIDLen = 128
+
+type requestIDKey struct{}
+
+// WithRequestID returns context with request identifier.
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestIDFromContext returns request identifier stored in ctx or empty
+// string.
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// RequestIDMiddleware is a HTTP middleware that accepts request identifier
+// from X-Request-ID header or assigns a new one, stores it in request context
+// and echoes it in response.
+type RequestIDMiddleware struct {
+	Inner http.Handler
+}
+
+func (m RequestIDMiddleware) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	id := EnsureRequestID(r.Header.Get(RequestIDHeader))
+
+	w.Header().Set(RequestIDHeader, id)
+	m.Inner.ServeHTTP(w, r.WithContext(WithRequestID(r.Context(), id)))
+}
+
+// EnsureRequestID returns id if it's a valid request identifier, otherwise it
+// returns a new identifier.
+func EnsureRequestID(id string) string {
+	if !validRequestID(id) {
+		return uuid.New().String()
+	}
+	return id
+}
+
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLen {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] < 0x21 || id[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
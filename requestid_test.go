@@ -0,0 +1,52 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRequestIDMiddlewareAccept(t *testing.T) {
+	t.Parallel()
+
+	var got string
+	m := RequestIDMiddleware{Inner: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = RequestIDFromContext(r.Context())
+	})}
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set(RequestIDHeader, "test-id")
+	w := httptest.NewRecorder()
+	m.ServeHTTP(w, r)
+
+	if got != "test-id" {
+		t.Fatal("wrong request id in context", got)
+	}
+	if w.Header().Get(RequestIDHeader) != "test-id" {
+		t.Fatal("wrong request id in response", w.Header())
+	}
+}
+
+func TestRequestIDMiddlewareAssign(t *testing.T) {
+	t.Parallel()
+
+	for _, id := range []string{"", "with space", strings.Repeat("x", maxRequestIDLen+1)} {
+		var got string
+		m := RequestIDMiddleware{Inner: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			got = RequestIDFromContext(r.Context())
+		})}
+
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.Header.Set(RequestIDHeader, id)
+		w := httptest.NewRecorder()
+		m.ServeHTTP(w, r)
+
+		if got == "" || got == id {
+			t.Fatal("request id not assigned", id, got)
+		}
+		if w.Header().Get(RequestIDHeader) != got {
+			t.Fatal("wrong request id in response", w.Header())
+		}
+	}
+}
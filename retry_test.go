@@ -0,0 +1,76 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/go-kit/kit/log"
+	"github.com/golang/mock/gomock"
+)
+
+func TestServiceRetryTask(t *testing.T) {
+	t.Parallel()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	m := NewMockRemoteClient(ctrl)
+	gomock.InOrder(
+		m.EXPECT().Update(gomock.Any(), "addr0", "info").Return(nil),
+		m.EXPECT().Update(gomock.Any(), "addr1", "info").Return(errors.New("boom")),
+		m.EXPECT().Update(gomock.Any(), "addr1", "info").Return(nil),
+		m.EXPECT().Update(gomock.Any(), "addr2", "info").Return(nil),
+	)
+
+	s := NewService(m, []string{"addr0", "addr1", "addr2"}, log.NewNopLogger()).(*service)
+	ctx := context.Background()
+
+	id, err := s.CreateTask(ctx, &TaskConfig{Mode: Sequential, Info: "info", FailOnError: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-s.tasks[id].done
+
+	if _, err := s.RetryTask(ctx, id, RetryOptions{Statuses: []Status{Success}}); err == nil {
+		t.Fatal("expected error")
+	}
+
+	child, err := s.RetryTask(ctx, id, RetryOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-s.tasks[child].done
+
+	st, _ := s.TaskStatus(ctx, id)
+	if !reflect.DeepEqual(st.Children, []TaskID{child}) {
+		t.Fatal("wrong children", st)
+	}
+
+	st, _ = s.TaskStatus(ctx, child)
+	if !reflect.DeepEqual(st, &TaskStatus{
+		ID:       child,
+		State:    StateDone,
+		ParentID: id,
+		Results: []Result{
+			{
+				Addr:   "addr1",
+				Status: Success,
+			},
+			{
+				Addr:   "addr2",
+				Status: Success,
+			},
+		},
+	}) {
+		t.Fatal("wrong status", st)
+	}
+
+	if _, err := s.RetryTask(ctx, child, RetryOptions{}); err == nil {
+		t.Fatal("expected error")
+	}
+
+	if v, err := s.RetryTask(ctx, "missing", RetryOptions{}); v != "" || err != nil {
+		t.Fatal("expected not found", v, err)
+	}
+}
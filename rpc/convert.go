@@ -0,0 +1,137 @@
+package rpc
+
+import (
+	"time"
+
+	"github.com/mmatczuk/proxy"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+var errNotFound = status.Error(codes.NotFound, "task not found")
+
+// toError maps service errors to gRPC status errors.
+func toError(err error) error {
+	if err == proxy.ErrShutdown {
+		return status.Error(codes.Unavailable, err.Error())
+	}
+
+	switch err.(type) {
+	case *proxy.ConfigError:
+		return status.Error(codes.InvalidArgument, err.Error())
+	case *proxy.PermissionError:
+		return status.Error(codes.PermissionDenied, err.Error())
+	default:
+		return status.Error(codes.Internal, err.Error())
+	}
+}
+
+func toConfig(req *CreateTaskRequest) (*proxy.TaskConfig, error) {
+	c := &proxy.TaskConfig{
+		ClientID:         req.ClientId,
+		Info:             req.Info,
+		Mode:             proxy.TaskMode(req.Mode),
+		FailOnError:      req.FailOnError,
+		Schedule:         req.Schedule,
+		CompensationInfo: req.CompensationInfo,
+		PrepareInfo:      req.PrepareInfo,
+		AbortInfo:        req.AbortInfo,
+		Required:         proxy.Threshold(req.Required),
+		CancelRemaining:  req.CancelRemaining,
+		Condition:        proxy.Condition(req.Condition),
+	}
+	for _, id := range req.DependsOn {
+		c.DependsOn = append(c.DependsOn, proxy.TaskID(id))
+	}
+	for _, s := range req.Steps {
+		c.Steps = append(c.Steps, proxy.Step{
+			Name:        s.Name,
+			Info:        s.Info,
+			Addrs:       s.Addrs,
+			Mode:        proxy.TaskMode(s.Mode),
+			FailOnError: s.FailOnError,
+		})
+	}
+
+	if req.NotBefore != "" {
+		t, err := time.Parse(time.RFC3339, req.NotBefore)
+		if err != nil {
+			return nil, err
+		}
+		c.NotBefore = &t
+	}
+	if req.Delay != "" {
+		d, err := time.ParseDuration(req.Delay)
+		if err != nil {
+			return nil, err
+		}
+		c.Delay = proxy.Duration(d)
+	}
+	if req.HedgeDelay != "" {
+		d, err := time.ParseDuration(req.HedgeDelay)
+		if err != nil {
+			return nil, err
+		}
+		c.HedgeDelay = proxy.Duration(d)
+	}
+
+	return c, nil
+}
+
+func fromPlan(p *proxy.Plan) *Plan {
+	v := &Plan{
+		Mode:        string(p.Mode),
+		FailOnError: p.FailOnError,
+		Schedule:    p.Schedule,
+	}
+	if p.StartAt != nil {
+		v.StartAt = p.StartAt.Format(time.RFC3339Nano)
+	}
+	for _, s := range p.Steps {
+		step := new(PlanStep)
+		for _, c := range s.Calls {
+			step.Calls = append(step.Calls, &PlanCall{
+				Addr:  c.Addr,
+				Info:  c.Info,
+				Phase: string(c.Phase),
+				Step:  c.Step,
+			})
+		}
+		v.Steps = append(v.Steps, step)
+	}
+	return v
+}
+
+func fromStatus(t *proxy.TaskStatus) *TaskStatus {
+	v := &TaskStatus{
+		Id:         string(t.ID),
+		ClientId:   t.ClientID,
+		State:      string(t.State),
+		RequestId:  t.RequestID,
+		Schedule:   t.Schedule,
+		KillReason: t.KillReason,
+		ParentId:   string(t.ParentID),
+		Verdict:    string(t.Verdict),
+		Step:       t.Step,
+	}
+	if t.StartAt != nil {
+		v.StartAt = t.StartAt.Format(time.RFC3339Nano)
+	}
+	for _, id := range t.Children {
+		v.Children = append(v.Children, string(id))
+	}
+	for _, id := range t.DependsOn {
+		v.DependsOn = append(v.DependsOn, string(id))
+	}
+	for _, r := range t.Results {
+		v.Results = append(v.Results, &Result{
+			Addr:       r.Addr,
+			Status:     string(r.Status),
+			Message:    r.Msg,
+			KillReason: r.KillReason,
+			Phase:      string(r.Phase),
+			Step:       r.Step,
+		})
+	}
+	return v
+}
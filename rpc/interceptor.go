@@ -0,0 +1,139 @@
+package rpc
+
+import (
+	"context"
+	"net"
+	"strings"
+
+	"github.com/mmatczuk/proxy"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// Metadata keys, gRPC metadata keys are lower case.
+const (
+	requestIDKey = "x-request-id"
+	apiKeyKey    = "x-api-key"
+)
+
+// serverStream is a grpc.ServerStream with replaced context.
+type serverStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s serverStream) Context() context.Context {
+	return s.ctx
+}
+
+// RequestIDInterceptor accepts request identifier from x-request-id metadata
+// or assigns a new one, stores it in context and sends it in response header.
+type RequestIDInterceptor struct{}
+
+func (RequestIDInterceptor) requestID(ctx context.Context) context.Context {
+	var id string
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if v := md.Get(requestIDKey); len(v) > 0 {
+			id = v[0]
+		}
+	}
+	id = proxy.EnsureRequestID(id)
+
+	grpc.SetHeader(ctx, metadata.Pairs(requestIDKey, id))
+	return proxy.WithRequestID(ctx, id)
+}
+
+// Unary is a grpc.UnaryServerInterceptor.
+func (i RequestIDInterceptor) Unary(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	return handler(i.requestID(ctx), req)
+}
+
+// Stream is a grpc.StreamServerInterceptor.
+func (i RequestIDInterceptor) Stream(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	return handler(srv, serverStream{ss, i.requestID(ss.Context())})
+}
+
+// SourceIPInterceptor stores IP address of the caller in context.
+type SourceIPInterceptor struct{}
+
+func (SourceIPInterceptor) sourceIP(ctx context.Context) context.Context {
+	p, ok := peer.FromContext(ctx)
+	if !ok || p.Addr == nil {
+		return ctx
+	}
+	ip, _, err := net.SplitHostPort(p.Addr.String())
+	if err != nil {
+		ip = p.Addr.String()
+	}
+	return proxy.WithSourceIP(ctx, ip)
+}
+
+// Unary is a grpc.UnaryServerInterceptor.
+func (i SourceIPInterceptor) Unary(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	return handler(i.sourceIP(ctx), req)
+}
+
+// Stream is a grpc.StreamServerInterceptor.
+func (i SourceIPInterceptor) Stream(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	return handler(srv, serverStream{ss, i.sourceIP(ss.Context())})
+}
+
+// AuthInterceptor rejects unauthenticated calls and stores caller identity in
+// context, it's a gRPC counterpart of proxy.AuthMiddleware. Clients pass API
+// key in x-api-key metadata or as a bearer token in authorization metadata.
+type AuthInterceptor struct {
+	Auth   *proxy.Authenticator
+	Policy *proxy.Policy
+}
+
+func (i AuthInterceptor) authenticate(ctx context.Context) (context.Context, error) {
+	var id *proxy.Identity
+
+	if p, ok := peer.FromContext(ctx); ok {
+		if info, ok := p.AuthInfo.(credentials.TLSInfo); ok {
+			id = i.Auth.AuthenticateTLS(&info.State)
+		}
+	}
+	if id == nil {
+		if md, ok := metadata.FromIncomingContext(ctx); ok {
+			var key string
+			if v := md.Get(apiKeyKey); len(v) > 0 {
+				key = v[0]
+			} else if v := md.Get("authorization"); len(v) > 0 && strings.HasPrefix(v[0], "Bearer ") {
+				key = strings.TrimPrefix(v[0], "Bearer ")
+			}
+			id = i.Auth.AuthenticateKey(key)
+		}
+	}
+
+	if id == nil {
+		return nil, status.Error(codes.Unauthenticated, "unauthenticated")
+	}
+	if !i.Policy.Authorize(id) {
+		return nil, status.Error(codes.PermissionDenied, "forbidden")
+	}
+
+	return proxy.WithIdentity(ctx, id), nil
+}
+
+// Unary is a grpc.UnaryServerInterceptor.
+func (i AuthInterceptor) Unary(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	ctx, err := i.authenticate(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+// Stream is a grpc.StreamServerInterceptor.
+func (i AuthInterceptor) Stream(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	ctx, err := i.authenticate(ss.Context())
+	if err != nil {
+		return err
+	}
+	return handler(srv, serverStream{ss, ctx})
+}
@@ -0,0 +1,178 @@
+package rpc
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"time"
+
+	"github.com/mmatczuk/proxy"
+	"github.com/mmatczuk/proxy/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative proxy.proto
+
+// WatchInterval specifies how often WatchTask checks task status.
+const WatchInterval = 100 * time.Millisecond
+
+type server struct {
+	UnimplementedProxyServer
+	service proxy.Service
+}
+
+// NewServer creates gRPC server exposing service, opts are passed to
+// grpc.NewServer.
+func NewServer(service proxy.Service, opts ...grpc.ServerOption) *grpc.Server {
+	if service == nil {
+		panic("missing service")
+	}
+
+	s := grpc.NewServer(opts...)
+	RegisterProxyServer(s, &server{service: service})
+
+	return s
+}
+
+func (s *server) CreateTask(ctx context.Context, req *CreateTaskRequest) (*CreateTaskResponse, error) {
+	c, err := toConfig(req)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if v := md.Get(trace.TraceparentHeader); len(v) > 0 {
+			h := http.Header{}
+			h.Set(trace.TraceparentHeader, v[0])
+			if sc, ok := trace.Extract(h); ok {
+				ctx = trace.WithRemoteSpanContext(ctx, sc)
+			}
+		}
+	}
+	ctx, span := trace.Start(ctx, "rpc.createTask")
+	defer span.End()
+
+	id, err := s.service.CreateTask(ctx, c)
+	if err != nil {
+		span.SetError(err)
+		return nil, toError(err)
+	}
+
+	span.SetAttributes("task", id)
+	return &CreateTaskResponse{Id: string(id)}, nil
+}
+
+func (s *server) PlanTask(ctx context.Context, req *CreateTaskRequest) (*Plan, error) {
+	config, err := toConfig(req)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	p, err := s.service.PlanTask(ctx, config)
+	if err != nil {
+		return nil, toError(err)
+	}
+	return fromPlan(p), nil
+}
+
+func (s *server) TaskStatus(ctx context.Context, req *TaskRequest) (*TaskStatus, error) {
+	t, err := s.service.TaskStatus(ctx, proxy.TaskID(req.Id))
+	if err != nil {
+		return nil, toError(err)
+	}
+	if t == nil {
+		return nil, errNotFound
+	}
+	return fromStatus(t), nil
+}
+
+func (s *server) KillTask(ctx context.Context, req *KillTaskRequest) (*TaskStatus, error) {
+	t, err := s.service.KillTask(ctx, proxy.TaskID(req.Id), proxy.KillOptions{
+		Reason:  req.Reason,
+		Addrs:   req.Addrs,
+		Async:   req.Async,
+		Cascade: req.Cascade,
+	})
+	if err != nil {
+		return nil, toError(err)
+	}
+	if t == nil {
+		return nil, errNotFound
+	}
+	return fromStatus(t), nil
+}
+
+func (s *server) PauseTask(ctx context.Context, req *TaskRequest) (*TaskStatus, error) {
+	t, err := s.service.PauseTask(ctx, proxy.TaskID(req.Id))
+	if err != nil {
+		return nil, toError(err)
+	}
+	if t == nil {
+		return nil, errNotFound
+	}
+	return fromStatus(t), nil
+}
+
+func (s *server) ResumeTask(ctx context.Context, req *TaskRequest) (*TaskStatus, error) {
+	t, err := s.service.ResumeTask(ctx, proxy.TaskID(req.Id))
+	if err != nil {
+		return nil, toError(err)
+	}
+	if t == nil {
+		return nil, errNotFound
+	}
+	return fromStatus(t), nil
+}
+
+func (s *server) RetryTask(ctx context.Context, req *RetryTaskRequest) (*CreateTaskResponse, error) {
+	var opts proxy.RetryOptions
+	for _, v := range req.Statuses {
+		opts.Statuses = append(opts.Statuses, proxy.Status(v))
+	}
+	id, err := s.service.RetryTask(ctx, proxy.TaskID(req.Id), opts)
+	if err != nil {
+		return nil, toError(err)
+	}
+	if id == "" {
+		return nil, errNotFound
+	}
+	return &CreateTaskResponse{Id: string(id)}, nil
+}
+
+// WatchTask sends task status whenever it changes until the task is done.
+func (s *server) WatchTask(req *TaskRequest, stream Proxy_WatchTaskServer) error {
+	ctx := stream.Context()
+
+	ticker := time.NewTicker(WatchInterval)
+	defer ticker.Stop()
+
+	var last *proxy.TaskStatus
+	for {
+		t, err := s.service.TaskStatus(ctx, proxy.TaskID(req.Id))
+		if err != nil {
+			return toError(err)
+		}
+		if t == nil {
+			return errNotFound
+		}
+
+		if last == nil || !reflect.DeepEqual(t, last) {
+			if err := stream.Send(fromStatus(t)); err != nil {
+				return err
+			}
+			last = t
+		}
+		if t.State == proxy.StateDone {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return status.FromContextError(ctx.Err()).Err()
+		case <-ticker.C:
+		}
+	}
+}
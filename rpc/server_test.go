@@ -0,0 +1,248 @@
+package rpc
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net"
+	"testing"
+
+	"github.com/go-kit/kit/log"
+	"github.com/golang/mock/gomock"
+	"github.com/mmatczuk/proxy"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/proto"
+)
+
+// dial starts server s and returns client connected to it.
+func dial(t *testing.T, s *grpc.Server) ProxyClient {
+	l := bufconn.Listen(1 << 20)
+	go s.Serve(l)
+	t.Cleanup(s.Stop)
+
+	cc, err := grpc.Dial("bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, addr string) (net.Conn, error) {
+			return l.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { cc.Close() })
+
+	return NewProxyClient(cc)
+}
+
+func TestServerCreateTask(t *testing.T) {
+	t.Parallel()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	m := proxy.NewMockService(ctrl)
+	m.EXPECT().CreateTask(gomock.Any(), &proxy.TaskConfig{
+		ClientID:    "f0a4fd40-44bf-4535-b807-632586645d6f",
+		Info:        "test",
+		Mode:        proxy.Sequential,
+		FailOnError: true,
+	}).Return(proxy.TaskID("test"), nil)
+	c := dial(t, NewServer(m))
+
+	resp, err := c.CreateTask(context.Background(), &CreateTaskRequest{
+		ClientId:    "f0a4fd40-44bf-4535-b807-632586645d6f",
+		Info:        "test",
+		Mode:        "sequential",
+		FailOnError: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Id != "test" {
+		t.Fatal("wrong id", resp.Id)
+	}
+}
+
+func TestServerCreateTaskConfigError(t *testing.T) {
+	t.Parallel()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	m := proxy.NewMockService(ctrl)
+	m.EXPECT().CreateTask(gomock.Any(), gomock.Any()).Return(proxy.TaskID(""), &proxy.ConfigError{Msg: "invalid mode"})
+	c := dial(t, NewServer(m))
+
+	_, err := c.CreateTask(context.Background(), &CreateTaskRequest{Mode: "foo"})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Fatal("wrong error", err)
+	}
+
+	_, err = c.CreateTask(context.Background(), &CreateTaskRequest{Delay: "foo"})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Fatal("wrong error", err)
+	}
+}
+
+func TestServerTaskStatus(t *testing.T) {
+	t.Parallel()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	m := proxy.NewMockService(ctrl)
+	m.EXPECT().TaskStatus(gomock.Any(), proxy.TaskID("test")).Return(&proxy.TaskStatus{
+		ID:    "test",
+		State: proxy.StateDone,
+		Results: []proxy.Result{
+			{Addr: "a", Status: proxy.Success},
+			{Addr: "b", Status: proxy.Failure, Msg: "foobar"},
+		},
+	}, nil)
+	m.EXPECT().TaskStatus(gomock.Any(), proxy.TaskID("missing")).Return(nil, nil)
+	c := dial(t, NewServer(m))
+
+	s, err := c.TaskStatus(context.Background(), &TaskRequest{Id: "test"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := &TaskStatus{
+		Id:    "test",
+		State: "done",
+		Results: []*Result{
+			{Addr: "a", Status: "success"},
+			{Addr: "b", Status: "failure", Message: "foobar"},
+		},
+	}
+	if !proto.Equal(s, expected) {
+		t.Fatal("wrong status", s)
+	}
+
+	_, err = c.TaskStatus(context.Background(), &TaskRequest{Id: "missing"})
+	if status.Code(err) != codes.NotFound {
+		t.Fatal("wrong error", err)
+	}
+}
+
+func TestServerKillTaskPermissionError(t *testing.T) {
+	t.Parallel()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	m := proxy.NewMockService(ctrl)
+	m.EXPECT().KillTask(gomock.Any(), proxy.TaskID("test"), proxy.KillOptions{}).Return(nil, &proxy.PermissionError{Msg: "kill not permitted"})
+	c := dial(t, NewServer(m))
+
+	_, err := c.KillTask(context.Background(), &KillTaskRequest{Id: "test"})
+	if status.Code(err) != codes.PermissionDenied {
+		t.Fatal("wrong error", err)
+	}
+}
+
+func TestServerWatchTask(t *testing.T) {
+	t.Parallel()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	called := make(chan struct{})
+	release := make(chan struct{})
+	m := proxy.NewMockRemoteClient(ctrl)
+	m.EXPECT().Update(gomock.Any(), "a", "test").Return(nil).Do(func(ctx context.Context, addr, info string) {
+		close(called)
+		<-release
+	})
+	c := dial(t, NewServer(proxy.NewService(m, []string{"a"}, log.NewNopLogger())))
+
+	ctx := context.Background()
+	resp, err := c.CreateTask(ctx, &CreateTaskRequest{Info: "test", Mode: "sequential"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-called
+
+	stream, err := c.WatchTask(ctx, &TaskRequest{Id: resp.Id})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := stream.Recv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.State != "running" || s.Results[0].Status != "running" {
+		t.Fatal("wrong status", s)
+	}
+	close(release)
+
+	for {
+		v, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		s = v
+	}
+	if s.State != "done" || s.Results[0].Status != "success" {
+		t.Fatal("wrong final status", s)
+	}
+}
+
+func TestAuthInterceptor(t *testing.T) {
+	t.Parallel()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	h := sha256.Sum256([]byte("secret"))
+	a, err := proxy.NewAuthenticator(&proxy.AuthConfig{
+		Keys: []proxy.AuthKey{
+			{ClientID: "client:key", SHA256: hex.EncodeToString(h[:])},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m := proxy.NewMockService(ctrl)
+	m.EXPECT().TaskStatus(gomock.Any(), proxy.TaskID("test")).DoAndReturn(func(ctx context.Context, id proxy.TaskID) (*proxy.TaskStatus, error) {
+		if v := proxy.IdentityFromContext(ctx); v == nil || v.ClientID != "client:key" {
+			t.Error("wrong identity", v)
+		}
+		if proxy.RequestIDFromContext(ctx) != "test-id" {
+			t.Error("wrong request id", proxy.RequestIDFromContext(ctx))
+		}
+		return &proxy.TaskStatus{ID: id, State: proxy.StateDone}, nil
+	})
+
+	ai := AuthInterceptor{Auth: a}
+	ri := RequestIDInterceptor{}
+	c := dial(t, NewServer(m,
+		grpc.ChainUnaryInterceptor(ri.Unary, ai.Unary),
+		grpc.ChainStreamInterceptor(ri.Stream, ai.Stream),
+	))
+
+	_, err = c.TaskStatus(context.Background(), &TaskRequest{Id: "test"})
+	if status.Code(err) != codes.Unauthenticated {
+		t.Fatal("wrong error", err)
+	}
+
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "x-api-key", "secret", "x-request-id", "test-id")
+	var header metadata.MD
+	_, err = c.TaskStatus(ctx, &TaskRequest{Id: "test"}, grpc.Header(&header))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v := header.Get("x-request-id"); len(v) == 0 || v[0] != "test-id" {
+		t.Fatal("wrong request id header", header)
+	}
+}
@@ -0,0 +1,165 @@
+package proxy
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mmatczuk/proxy/log"
+	"github.com/robfig/cron"
+)
+
+// schedule creates a new task on every tick of a cron schedule.
+type schedule struct {
+	// id is schedule identifier, it shares namespace with tasks.
+	id TaskID
+	// requestID is identifier of the request that created the schedule, it's
+	// passed on to spawned tasks.
+	requestID string
+	// spec is the cron expression.
+	spec string
+	// cron calculates activation times.
+	cron cron.Schedule
+	// config is used to create tasks, it has no schedule.
+	config TaskConfig
+	// spawn creates and registers a new task.
+	spawn func(ctx context.Context, config *TaskConfig) (*task, error)
+	// context is cancelled when schedule is killed.
+	context context.Context
+	// cancel enables killing schedule.
+	cancel context.CancelFunc
+	// created is schedule creation time.
+	created time.Time
+	// next is the time of the next activation.
+	next time.Time
+	// children contains identifiers of spawned tasks.
+	children []TaskID
+	// killReason is the reason given when the schedule was killed.
+	killReason string
+	// mu protects next, children and killReason
+	mu sync.RWMutex
+	// done is closed when schedule is done
+	done chan struct{}
+	// logger
+	logger log.Logger
+}
+
+// newSchedule creates new schedule based on configuration and starts spawning
+// tasks.
+func newSchedule(ctx context.Context, config *TaskConfig, spawn func(ctx context.Context, config *TaskConfig) (*task, error), logger log.Logger) (*schedule, error) {
+	c, err := cron.ParseStandard(config.Schedule)
+	if err != nil {
+		return nil, err
+	}
+
+	u, err := uuid.NewUUID()
+	if err != nil {
+		return nil, err
+	}
+
+	s := &schedule{
+		id:        TaskID(u.String()),
+		requestID: RequestIDFromContext(ctx),
+		spec:      config.Schedule,
+		cron:      c,
+		config:    *config,
+		spawn:     spawn,
+		created:   time.Now(),
+		done:      make(chan struct{}),
+	}
+	s.logger = log.With(logger, "schedule", s.id)
+	if s.requestID != "" {
+		s.logger = log.With(s.logger, "request_id", s.requestID)
+	}
+	s.config.Schedule = ""
+	s.context, s.cancel = context.WithCancel(context.Background())
+	s.next = s.cron.Next(s.created)
+
+	go s.run()
+
+	return s, nil
+}
+
+func (s *schedule) run() {
+	defer close(s.done)
+
+	for {
+		s.mu.RLock()
+		next := s.next
+		s.mu.RUnlock()
+
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-timer.C:
+		case <-s.context.Done():
+			timer.Stop()
+			return
+		}
+
+		ctx := context.Background()
+		if s.requestID != "" {
+			ctx = WithRequestID(ctx, s.requestID)
+		}
+
+		config := s.config
+		t, err := s.spawn(ctx, &config)
+		if err != nil {
+			log.Error(s.logger).Log(
+				"msg", "failed to spawn task",
+				"err", err,
+			)
+		}
+
+		s.mu.Lock()
+		if t != nil {
+			s.children = append(s.children, t.ID())
+		}
+		s.next = s.cron.Next(time.Now())
+		s.mu.Unlock()
+	}
+}
+
+// ID returns schedule identifier.
+func (s *schedule) ID() TaskID {
+	return s.id
+}
+
+func (s *schedule) status() *TaskStatus {
+	st := TaskStatus{
+		ID:        s.id,
+		ClientID:  s.config.ClientID,
+		State:     StateScheduled,
+		RequestID: s.requestID,
+		Schedule:  s.spec,
+		Results:   []Result{},
+	}
+
+	select {
+	case <-s.done:
+		st.State = StateDone
+	default:
+	}
+
+	s.mu.RLock()
+	if st.State == StateScheduled {
+		next := s.next
+		st.StartAt = &next
+	}
+	st.Children = append([]TaskID(nil), s.children...)
+	st.KillReason = s.killReason
+	s.mu.RUnlock()
+
+	return &st
+}
+
+// kill stops spawning tasks, it does not wait for the schedule to finish.
+func (s *schedule) kill(reason string) {
+	s.mu.Lock()
+	if s.killReason == "" && s.context.Err() == nil {
+		s.killReason = reason
+	}
+	s.mu.Unlock()
+
+	s.cancel()
+}
@@ -0,0 +1,71 @@
+package proxy
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/golang/mock/gomock"
+)
+
+func TestScheduleSpawnsTasks(t *testing.T) {
+	t.Parallel()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	m := NewMockRemoteClient(ctrl)
+	m.EXPECT().Update(gomock.Any(), "addr0", "info").Return(nil).MinTimes(1)
+
+	s := NewService(m, []string{"addr0"}, log.NewNopLogger())
+
+	id, err := s.CreateTask(context.Background(), &TaskConfig{
+		Mode:     Sequential,
+		Info:     "info",
+		Schedule: "@every 1s",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	st, _ := s.TaskStatus(context.Background(), id)
+	if st.State != StateScheduled || st.Schedule != "@every 1s" || st.StartAt == nil {
+		t.Fatal("wrong status", st)
+	}
+
+	for len(st.Children) == 0 {
+		time.Sleep(100 * time.Millisecond)
+		st, _ = s.TaskStatus(context.Background(), id)
+	}
+
+	st, _ = s.KillTask(context.Background(), id, KillOptions{})
+	if st.State != StateDone || st.StartAt != nil {
+		t.Fatal("wrong status", st)
+	}
+
+	child, _ := s.KillTask(context.Background(), st.Children[0], KillOptions{})
+	if child == nil || child.State != StateDone {
+		t.Fatal("wrong child status", child)
+	}
+
+	l, _ := s.ListTasks(context.Background())
+	if len(l) != len(st.Children)+1 || l[0].ID != id {
+		t.Fatal("wrong list", l)
+	}
+}
+
+func TestServiceCreateTaskInvalidSchedule(t *testing.T) {
+	t.Parallel()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	s := NewService(NewMockRemoteClient(ctrl), []string{"addr0"}, log.NewNopLogger())
+
+	_, err := s.CreateTask(context.Background(), &TaskConfig{
+		Mode:     Sequential,
+		Schedule: "not a cron",
+	})
+	if _, ok := err.(*ConfigError); !ok {
+		t.Fatal("expected config error", err)
+	}
+}
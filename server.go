@@ -1,9 +1,11 @@
 package proxy
 
 import (
+	"io"
 	"net/http"
 
 	"github.com/gorilla/mux"
+	"github.com/mmatczuk/proxy/trace"
 )
 
 type server struct {
@@ -25,6 +27,7 @@ func NewServer(service Service) http.Handler {
 
 func router(s *server) http.Handler {
 	r := mux.NewRouter()
+	r.Use(routeMiddleware)
 
 	api := r.PathPrefix("/v1").Subrouter()
 
@@ -33,6 +36,21 @@ func router(s *server) http.Handler {
 		Methods(http.MethodPost).
 		HandlerFunc(s.createTask)
 
+	api.
+		Path("/task").
+		Methods(http.MethodGet).
+		HandlerFunc(s.listTasks)
+
+	api.
+		Path("/task/plan").
+		Methods(http.MethodPost).
+		HandlerFunc(s.planTask)
+
+	api.
+		Path("/task/{id}").
+		Methods(http.MethodGet).
+		HandlerFunc(s.getTask)
+
 	api.
 		Path("/task/{id}/status").
 		Methods(http.MethodGet).
@@ -40,12 +58,45 @@ func router(s *server) http.Handler {
 
 	api.
 		Path("/task/{id}/kill").
-		Methods(http.MethodGet).
+		Methods(http.MethodPost).
 		HandlerFunc(s.killTask)
 
+	api.
+		Path("/task/{id}/pause").
+		Methods(http.MethodPost).
+		HandlerFunc(s.pauseTask)
+
+	api.
+		Path("/task/{id}/resume").
+		Methods(http.MethodPost).
+		HandlerFunc(s.resumeTask)
+
+	api.
+		Path("/task/{id}/retry").
+		Methods(http.MethodPost).
+		HandlerFunc(s.retryTask)
+
+	// deprecated, use POST
+	api.
+		Path("/task/{id}/kill").
+		Methods(http.MethodGet).
+		HandlerFunc(s.killTaskDeprecated)
+
 	return r
 }
 
+// routeMiddleware passes matched route template to LoggingMiddleware.
+func routeMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if route := mux.CurrentRoute(r); route != nil {
+			if tpl, err := route.GetPathTemplate(); err == nil {
+				setRoute(w, tpl)
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
 func (s *server) createTask(w http.ResponseWriter, r *http.Request) {
 	var c TaskConfig
 	if err := readJSON(&c, r.Body); err != nil {
@@ -53,21 +104,65 @@ func (s *server) createTask(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	id, err := s.service.CreateTask(r.Context(), &c)
+	ctx := r.Context()
+	if sc, ok := trace.Extract(r.Header); ok {
+		ctx = trace.WithRemoteSpanContext(ctx, sc)
+	}
+	ctx, span := trace.Start(ctx, "server.createTask")
+	defer span.End()
+
+	id, err := s.service.CreateTask(ctx, &c)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		span.SetError(err)
+		writeError(w, err)
 		return
 	}
 
+	span.SetAttributes("task", id)
 	writeJSON(w, http.StatusCreated, id)
 }
 
+func (s *server) planTask(w http.ResponseWriter, r *http.Request) {
+	var c TaskConfig
+	if err := readJSON(&c, r.Body); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	p, err := s.service.PlanTask(r.Context(), &c)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, p)
+}
+
+// getTask returns task status.
+func (s *server) getTask(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	t, err := s.service.TaskStatus(r.Context(), TaskID(id))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	if t == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, t)
+}
+
+// taskStatus returns results of the task.
 func (s *server) taskStatus(w http.ResponseWriter, r *http.Request) {
 	id := mux.Vars(r)["id"]
 
 	t, err := s.service.TaskStatus(r.Context(), TaskID(id))
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, err)
 		return
 	}
 
@@ -79,12 +174,77 @@ func (s *server) taskStatus(w http.ResponseWriter, r *http.Request) {
 	writeJSON(w, http.StatusOK, t.Results)
 }
 
+func (s *server) listTasks(w http.ResponseWriter, r *http.Request) {
+	state := TaskState(r.URL.Query().Get("state"))
+
+	l, err := s.service.ListTasks(r.Context())
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	tasks := []*TaskStatus{}
+	for _, t := range l {
+		if state == "" || t.State == state {
+			tasks = append(tasks, t)
+		}
+	}
+
+	writeJSON(w, http.StatusOK, tasks)
+}
+
+// killRequest is an optional body of kill request.
+type killRequest struct {
+	Reason string   `json:"reason"`
+	Addrs  []string `json:"addrs"`
+	// Wait specifies if request waits for the killed calls to finish,
+	// default is true.
+	Wait *bool `json:"wait"`
+	// Cascade kills also tasks depending on the task.
+	Cascade bool `json:"cascade"`
+}
+
 func (s *server) killTask(w http.ResponseWriter, r *http.Request) {
 	id := mux.Vars(r)["id"]
 
-	t, err := s.service.KillTask(r.Context(), TaskID(id))
+	var req killRequest
+	if err := readJSON(&req, r.Body); err != nil && err != io.EOF {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	opts := KillOptions{
+		Reason:  req.Reason,
+		Addrs:   req.Addrs,
+		Async:   req.Wait != nil && !*req.Wait,
+		Cascade: req.Cascade,
+	}
+	t, err := s.service.KillTask(r.Context(), TaskID(id), opts)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	if t == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	code := http.StatusOK
+	if opts.Async {
+		code = http.StatusAccepted
+	}
+	writeJSON(w, code, t)
+}
+
+// killTaskDeprecated kills task and returns killed results.
+func (s *server) killTaskDeprecated(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	w.Header().Set("Deprecation", "true")
+	t, err := s.service.KillTask(r.Context(), TaskID(id), KillOptions{})
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, err)
 		return
 	}
 
@@ -102,3 +262,82 @@ func (s *server) killTask(w http.ResponseWriter, r *http.Request) {
 
 	writeJSON(w, http.StatusOK, killed)
 }
+
+func (s *server) pauseTask(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	t, err := s.service.PauseTask(r.Context(), TaskID(id))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	if t == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, t)
+}
+
+func (s *server) resumeTask(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	t, err := s.service.ResumeTask(r.Context(), TaskID(id))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	if t == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, t)
+}
+
+// retryRequest is an optional body of retry request.
+type retryRequest struct {
+	Statuses []Status `json:"statuses"`
+}
+
+func (s *server) retryTask(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	var req retryRequest
+	if err := readJSON(&req, r.Body); err != nil && err != io.EOF {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	child, err := s.service.RetryTask(r.Context(), TaskID(id), RetryOptions{Statuses: req.Statuses})
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	if child == "" {
+		http.NotFound(w, r)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, child)
+}
+
+// writeError writes error response with status code based on error type.
+func writeError(w http.ResponseWriter, err error) {
+	if err == ErrShutdown {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+
+	switch err.(type) {
+	case *ConfigError:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case *PermissionError:
+		http.Error(w, err.Error(), http.StatusForbidden)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
@@ -65,6 +65,88 @@ func TestServerCreateTaskError(t *testing.T) {
 	}
 }
 
+func TestServerCreateTaskConfigError(t *testing.T) {
+	t.Parallel()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	m := NewMockService(ctrl)
+	m.EXPECT().CreateTask(gomock.Any(), gomock.Any()).Return(TaskID(""), &ConfigError{"foobar"})
+	s := NewServer(m)
+
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/v1/task", strings.NewReader("{}")))
+
+	if w.Code != http.StatusBadRequest {
+		t.Fatal("wrong status code", w)
+	}
+	if strings.TrimSpace(w.Body.String()) != "foobar" {
+		t.Fatal("wrong body", w)
+	}
+}
+
+func TestServerPlanTask(t *testing.T) {
+	t.Parallel()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	m := NewMockService(ctrl)
+	m.EXPECT().PlanTask(gomock.Any(), &TaskConfig{Info: "test", Mode: Parallel}).Return(&Plan{
+		Mode: Parallel,
+		Steps: []PlanStep{
+			{Calls: []PlanCall{{Addr: "addr", Info: "test"}}},
+		},
+	}, nil)
+	s := NewServer(m)
+
+	w := httptest.NewRecorder()
+	body := strings.NewReader(`{"info":"test","mode":"parallel"}`)
+	s.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/v1/task/plan", body))
+
+	if w.Code != http.StatusOK {
+		t.Fatal("wrong status code", w)
+	}
+	if strings.TrimSpace(w.Body.String()) != `{"mode":"parallel","failonerror":false,"steps":[{"calls":[{"addr":"addr","info":"test"}]}]}` {
+		t.Fatal("wrong body", w)
+	}
+}
+
+func TestServerListTasks(t *testing.T) {
+	t.Parallel()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	m := NewMockService(ctrl)
+	m.EXPECT().ListTasks(gomock.Any()).Return([]*TaskStatus{
+		{
+			ID:      "test:1",
+			State:   StateDone,
+			Results: []Result{},
+		},
+		{
+			ID:       "test:2",
+			State:    StateScheduled,
+			Schedule: "@daily",
+			Results:  []Result{},
+		},
+	}, nil)
+	s := NewServer(m)
+
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/task?state=scheduled", nil))
+
+	if w.Code != http.StatusOK {
+		t.Fatal("wrong status code", w)
+	}
+
+	if strings.TrimSpace(w.Body.String()) != `[{"id":"test:2","state":"scheduled","schedule":"@daily","results":[]}]` {
+		t.Fatal("wrong body", w)
+	}
+}
+
 func TestSeverTaskStatus(t *testing.T) {
 	t.Parallel()
 
@@ -99,6 +181,45 @@ func TestSeverTaskStatus(t *testing.T) {
 	}
 }
 
+func TestServerGetTask(t *testing.T) {
+	t.Parallel()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	m := NewMockService(ctrl)
+	m.EXPECT().TaskStatus(gomock.Any(), TaskID("test")).Return(&TaskStatus{
+		ID:    "test",
+		State: StateDone,
+		Results: []Result{
+			{
+				Addr:   "addr:1",
+				Status: Success,
+			},
+		},
+	}, nil)
+	m.EXPECT().TaskStatus(gomock.Any(), TaskID("missing")).Return(nil, nil)
+	s := NewServer(m)
+
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/task/test", nil))
+
+	if w.Code != http.StatusOK {
+		t.Fatal("wrong status code", w)
+	}
+
+	if strings.TrimSpace(w.Body.String()) != `{"id":"test","state":"done","results":[{"addr":"addr:1","status":"success"}]}` {
+		t.Fatal("wrong body", w)
+	}
+
+	w = httptest.NewRecorder()
+	s.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/task/missing", nil))
+
+	if w.Code != http.StatusNotFound {
+		t.Fatal("wrong status code", w)
+	}
+}
+
 func TestSeverTaskStatusError(t *testing.T) {
 	t.Parallel()
 
@@ -127,7 +248,7 @@ func TestSeverKillTask(t *testing.T) {
 	defer ctrl.Finish()
 
 	m := NewMockService(ctrl)
-	m.EXPECT().KillTask(gomock.Any(), TaskID("test")).Return(&TaskStatus{
+	m.EXPECT().KillTask(gomock.Any(), TaskID("test"), KillOptions{}).Return(&TaskStatus{
 		Results: []Result{
 			{
 				Addr:   "addr",
@@ -161,6 +282,122 @@ func TestSeverKillTask(t *testing.T) {
 	}
 }
 
+func TestServerKillTaskPost(t *testing.T) {
+	t.Parallel()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	m := NewMockService(ctrl)
+	m.EXPECT().KillTask(gomock.Any(), TaskID("test"), KillOptions{
+		Reason: "test",
+		Addrs:  []string{"addr"},
+		Async:  true,
+	}).Return(&TaskStatus{
+		ID:         "test",
+		State:      StateRunning,
+		KillReason: "test",
+	}, nil)
+	s := NewServer(m)
+
+	w := httptest.NewRecorder()
+	body := strings.NewReader(`{"reason":"test","addrs":["addr"],"wait":false}`)
+	s.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/v1/task/test/kill", body))
+
+	if w.Code != http.StatusAccepted {
+		t.Fatal("wrong status code", w)
+	}
+	if strings.TrimSpace(w.Body.String()) != `{"id":"test","state":"running","kill_reason":"test","results":null}` {
+		t.Fatal("wrong body", w)
+	}
+}
+
+func TestServerKillTaskPostNoBody(t *testing.T) {
+	t.Parallel()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	m := NewMockService(ctrl)
+	m.EXPECT().KillTask(gomock.Any(), TaskID("test"), KillOptions{}).Return(&TaskStatus{
+		ID:    "test",
+		State: StateDone,
+	}, nil)
+	s := NewServer(m)
+
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/v1/task/test/kill", nil))
+
+	if w.Code != http.StatusOK {
+		t.Fatal("wrong status code", w)
+	}
+}
+
+func TestServerPauseTask(t *testing.T) {
+	t.Parallel()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	m := NewMockService(ctrl)
+	m.EXPECT().PauseTask(gomock.Any(), TaskID("test")).Return(&TaskStatus{
+		ID:    "test",
+		State: StatePaused,
+	}, nil)
+	m.EXPECT().ResumeTask(gomock.Any(), TaskID("missing")).Return(nil, nil)
+	m.EXPECT().PauseTask(gomock.Any(), TaskID("parallel")).Return(nil, &ConfigError{"parallel task cannot be paused"})
+	s := NewServer(m)
+
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/v1/task/test/pause", nil))
+	if w.Code != http.StatusOK {
+		t.Fatal("wrong status code", w)
+	}
+	if strings.TrimSpace(w.Body.String()) != `{"id":"test","state":"paused","results":null}` {
+		t.Fatal("wrong body", w)
+	}
+
+	w = httptest.NewRecorder()
+	s.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/v1/task/missing/resume", nil))
+	if w.Code != http.StatusNotFound {
+		t.Fatal("wrong status code", w)
+	}
+
+	w = httptest.NewRecorder()
+	s.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/v1/task/parallel/pause", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Fatal("wrong status code", w)
+	}
+}
+
+func TestServerRetryTask(t *testing.T) {
+	t.Parallel()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	m := NewMockService(ctrl)
+	m.EXPECT().RetryTask(gomock.Any(), TaskID("test"), RetryOptions{Statuses: []Status{Failure}}).Return(TaskID("retry"), nil)
+	m.EXPECT().RetryTask(gomock.Any(), TaskID("missing"), RetryOptions{}).Return(TaskID(""), nil)
+	s := NewServer(m)
+
+	w := httptest.NewRecorder()
+	body := strings.NewReader(`{"statuses":["failure"]}`)
+	s.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/v1/task/test/retry", body))
+	if w.Code != http.StatusCreated {
+		t.Fatal("wrong status code", w)
+	}
+	if strings.TrimSpace(w.Body.String()) != `"retry"` {
+		t.Fatal("wrong body", w)
+	}
+
+	w = httptest.NewRecorder()
+	s.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/v1/task/missing/retry", nil))
+	if w.Code != http.StatusNotFound {
+		t.Fatal("wrong status code", w)
+	}
+}
+
 func TestSeverKillTaskError(t *testing.T) {
 	t.Parallel()
 
@@ -168,7 +405,7 @@ func TestSeverKillTaskError(t *testing.T) {
 	defer ctrl.Finish()
 
 	m := NewMockService(ctrl)
-	m.EXPECT().KillTask(gomock.Any(), TaskID("test")).Return(nil, errors.New("foobar"))
+	m.EXPECT().KillTask(gomock.Any(), TaskID("test"), KillOptions{}).Return(nil, errors.New("foobar"))
 	s := NewServer(m)
 
 	w := httptest.NewRecorder()
@@ -181,3 +418,24 @@ func TestSeverKillTaskError(t *testing.T) {
 		t.Fatal("wrong body", w)
 	}
 }
+
+func TestSeverKillTaskPermissionError(t *testing.T) {
+	t.Parallel()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	m := NewMockService(ctrl)
+	m.EXPECT().KillTask(gomock.Any(), TaskID("test"), KillOptions{}).Return(nil, &PermissionError{"foobar"})
+	s := NewServer(m)
+
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/task/test/kill", nil))
+
+	if w.Code != http.StatusForbidden {
+		t.Fatal("wrong status code", w)
+	}
+	if strings.TrimSpace(w.Body.String()) != "foobar" {
+		t.Fatal("wrong body", w)
+	}
+}
@@ -3,23 +3,64 @@ package proxy
 import (
 	"context"
 	"errors"
+	"fmt"
+	"sort"
 	"sync"
+	"time"
 
 	"github.com/mmatczuk/proxy/log"
+	"github.com/robfig/cron"
 )
 
 // Service provides proxy operations.
 type Service interface {
 	CreateTask(ctx context.Context, config *TaskConfig) (TaskID, error)
 	TaskStatus(ctx context.Context, id TaskID) (*TaskStatus, error)
-	KillTask(ctx context.Context, id TaskID) (*TaskStatus, error)
+	KillTask(ctx context.Context, id TaskID, opts KillOptions) (*TaskStatus, error)
+	PauseTask(ctx context.Context, id TaskID) (*TaskStatus, error)
+	ResumeTask(ctx context.Context, id TaskID) (*TaskStatus, error)
+	RetryTask(ctx context.Context, id TaskID, opts RetryOptions) (TaskID, error)
+	PlanTask(ctx context.Context, config *TaskConfig) (*Plan, error)
+	ListTasks(ctx context.Context) ([]*TaskStatus, error)
+}
+
+// ConfigError is returned by CreateTask if task configuration is invalid.
+type ConfigError struct {
+	Msg string
+}
+
+func (e *ConfigError) Error() string {
+	return e.Msg
+}
+
+// ErrShutdown is returned by CreateTask when service is shutting down.
+var ErrShutdown = errors.New("service is shutting down")
+
+// Shutdowner is implemented by services that support graceful shutdown.
+type Shutdowner interface {
+	// Shutdown stops accepting new tasks and waits for running tasks to
+	// finish, when ctx is done the remaining tasks are killed.
+	Shutdown(ctx context.Context) error
+}
+
+// PermissionError is returned if caller is not allowed to perform operation.
+type PermissionError struct {
+	Msg string
+}
+
+func (e *PermissionError) Error() string {
+	return e.Msg
 }
 
 type service struct {
-	client  RemoteClient
-	addrs   []string
-	tasks   map[TaskID]*task
-	tasksMu sync.RWMutex
+	client    RemoteClient
+	addrs     []string
+	tasks     map[TaskID]*task
+	schedules map[TaskID]*schedule
+	tasksMu   sync.RWMutex
+	// closing is set when service is shutting down, it's protected by
+	// tasksMu.
+	closing bool
 	logger  log.Logger
 }
 
@@ -36,35 +77,318 @@ func NewService(client RemoteClient, addrs []string, logger log.Logger) Service
 	}
 
 	return &service{
-		client: client,
-		addrs:  addrs,
-		tasks:  make(map[TaskID]*task),
-		logger: logger,
+		client:    client,
+		addrs:     addrs,
+		tasks:     make(map[TaskID]*task),
+		schedules: make(map[TaskID]*schedule),
+		logger:    logger,
 	}
 }
 
 func (s *service) CreateTask(ctx context.Context, config *TaskConfig) (TaskID, error) {
-	t, err := newTask(config, s.client, s.addrs, s.logger)
+	if err := checkCreate(ctx, config, s.addrs); err != nil {
+		return "", err
+	}
+
+	if config.Schedule != "" {
+		sc, err := newSchedule(ctx, config, s.newTask, s.logger)
+		if err != nil {
+			log.Error(s.logger).Log(
+				"msg", "failed to create schedule",
+				"client", config.ClientID,
+				"request_id", RequestIDFromContext(ctx),
+				"err", err,
+			)
+			return "", errors.New("failed to generate id")
+		}
+
+		s.tasksMu.Lock()
+		closing := s.closing
+		if !closing {
+			s.schedules[sc.ID()] = sc
+		}
+		s.tasksMu.Unlock()
+
+		if closing {
+			sc.kill(shutdownReason)
+			<-sc.done
+			return "", ErrShutdown
+		}
+
+		return sc.ID(), nil
+	}
+
+	deps, err := s.dependencies(ctx, config)
+	if err != nil {
+		return "", err
+	}
+
+	t, err := s.startTask(ctx, config, s.addrs, "", deps)
+	if err == ErrShutdown {
+		return "", err
+	}
+	if err != nil {
+		return "", errors.New("failed to generate id")
+	}
+
+	return t.ID(), nil
+}
+
+// dependencies returns tasks config depends on. Dependencies must be existing
+// tasks visible to caller in ctx, since a task cannot be referenced before
+// it's created dependency cycles are rejected.
+func (s *service) dependencies(ctx context.Context, config *TaskConfig) ([]*task, error) {
+	var deps []*task
+	for i, id := range config.DependsOn {
+		for _, v := range config.DependsOn[:i] {
+			if v == id {
+				return nil, &ConfigError{fmt.Sprintf("duplicate dependency %s", id)}
+			}
+		}
+
+		t, sc := s.get(ctx, id)
+		if sc != nil {
+			return nil, &ConfigError{fmt.Sprintf("cannot depend on recurring task %s", id)}
+		}
+		if t == nil {
+			return nil, &ConfigError{fmt.Sprintf("unknown dependency %s", id)}
+		}
+		deps = append(deps, t)
+	}
+	return deps, nil
+}
+
+// newTask creates and registers a new task, it fails with ErrShutdown if
+// service is shutting down.
+func (s *service) newTask(ctx context.Context, config *TaskConfig) (*task, error) {
+	return s.startTask(ctx, config, s.addrs, "", nil)
+}
+
+// startTask works like newTask for the given addresses, parentID is set for
+// tasks retrying another task, deps are tasks the task depends on.
+func (s *service) startTask(ctx context.Context, config *TaskConfig, addrs []string, parentID TaskID, deps []*task) (*task, error) {
+	s.tasksMu.Lock()
+	defer s.tasksMu.Unlock()
+
+	if s.closing {
+		return nil, ErrShutdown
+	}
+
+	t, err := newDependentTask(ctx, config, s.client, addrs, deps, s.logger)
 	if err != nil {
-		s.logger.Log(
+		log.Error(s.logger).Log(
 			"msg", "failed to create task",
+			"client", config.ClientID,
+			"request_id", RequestIDFromContext(ctx),
 			"err", err,
 		)
-		return "", errors.New("failed to generate id")
+		return nil, err
 	}
 
-	s.tasksMu.Lock()
+	t.parentID = parentID
 	s.tasks[t.ID()] = t
-	s.tasksMu.Unlock()
+	for _, d := range deps {
+		d.addDependent(t)
+	}
 
-	return t.ID(), nil
+	tasksCreated.WithLabelValues(string(config.Mode)).Inc()
+
+	return t, nil
 }
 
-func (s *service) TaskStatus(ctx context.Context, id TaskID) (*TaskStatus, error) {
+// checkCreate checks if caller in ctx may create task with config and
+// validates it for addrs, client ID defaults to caller's.
+func checkCreate(ctx context.Context, config *TaskConfig, addrs []string) error {
+	if err := checkPermission(ctx, PermissionCreate); err != nil {
+		return err
+	}
+	if id := IdentityFromContext(ctx); id != nil {
+		if config.ClientID == "" {
+			config.ClientID = id.ClientID
+		} else if config.ClientID != id.ClientID {
+			return &PermissionError{fmt.Sprintf("client %s cannot create tasks for client %s", id.ClientID, config.ClientID)}
+		}
+	}
+
+	if err := validateConfig(config); err != nil {
+		return err
+	}
+	if err := validateSteps(config, addrs); err != nil {
+		return err
+	}
+	n := len(addrs)
+	if config.Required.count(n) > n {
+		return &ConfigError{fmt.Sprintf("required %v exceeds number of addresses %d", config.Required, n)}
+	}
+	return nil
+}
+
+func (s *service) PlanTask(ctx context.Context, config *TaskConfig) (*Plan, error) {
+	if err := checkCreate(ctx, config, s.addrs); err != nil {
+		return nil, err
+	}
+	if _, err := s.dependencies(ctx, config); err != nil {
+		return nil, err
+	}
+
+	p := &Plan{
+		Mode:        config.Mode,
+		FailOnError: config.FailOnError,
+		Schedule:    config.Schedule,
+		Steps:       []PlanStep{},
+	}
+
+	now := time.Now()
+	if config.Schedule != "" {
+		c, err := cron.ParseStandard(config.Schedule)
+		if err != nil {
+			return nil, err
+		}
+		next := c.Next(now)
+		p.StartAt = &next
+	} else if startAt := startTime(config, now); !startAt.IsZero() {
+		p.StartAt = &startAt
+	}
+
+	if len(config.Steps) > 0 {
+		p.Steps = planStages(config, s.addrs)
+		return p, nil
+	}
+	if config.Mode == TwoPhase {
+		p.Steps = planTwoPhase(config, s.addrs)
+		return p, nil
+	}
+
+	for _, step := range planSteps(config.Mode, len(s.addrs)) {
+		var v PlanStep
+		for _, i := range step {
+			v.Calls = append(v.Calls, PlanCall{
+				Addr: s.addrs[i],
+				Info: config.Info,
+			})
+		}
+		p.Steps = append(p.Steps, v)
+	}
+
+	return p, nil
+}
+
+func validateConfig(config *TaskConfig) error {
+	switch config.Mode {
+	case Sequential, Parallel, TwoPhase, Quorum, Race:
+	default:
+		return &ConfigError{fmt.Sprintf("unsupported mode %q", config.Mode)}
+	}
+
+	if config.Mode == TwoPhase {
+		if config.CompensationInfo != "" {
+			return &ConfigError{"compensation_info is not supported in two_phase mode, use abort_info"}
+		}
+		if config.PrepareInfo == "" || config.AbortInfo == "" {
+			return &ConfigError{"two_phase mode requires prepare_info and abort_info"}
+		}
+	} else if config.PrepareInfo != "" || config.AbortInfo != "" {
+		return &ConfigError{"prepare_info and abort_info are supported only in two_phase mode"}
+	}
+	if config.Mode == Quorum {
+		if config.FailOnError {
+			return &ConfigError{"failonerror is not supported in quorum mode"}
+		}
+	} else if config.CancelRemaining {
+		return &ConfigError{"cancel_remaining is supported only in quorum mode"}
+	}
+	if config.Mode == Race {
+		if config.FailOnError {
+			return &ConfigError{"failonerror is not supported in race mode"}
+		}
+		if config.HedgeDelay < 0 {
+			return &ConfigError{"negative hedge_delay"}
+		}
+	} else if config.HedgeDelay != 0 {
+		return &ConfigError{"hedge_delay is supported only in race mode"}
+	}
+	switch config.Condition {
+	case "", ConditionSucceeded, ConditionFinished:
+	default:
+		return &ConfigError{fmt.Sprintf("unsupported condition %q", config.Condition)}
+	}
+	if config.Condition != "" && len(config.DependsOn) == 0 {
+		return &ConfigError{"condition requires depends_on"}
+	}
+
+	if config.Required != 0 && config.Mode != TwoPhase && config.Mode != Quorum {
+		return &ConfigError{"required is supported only in two_phase and quorum modes"}
+	}
+	if !config.Required.valid() {
+		return &ConfigError{"required must be a ratio lower than 1 or a number of calls"}
+	}
+
+	if config.Delay < 0 {
+		return &ConfigError{"negative delay"}
+	}
+	if config.NotBefore != nil && config.Delay != 0 {
+		return &ConfigError{"not_before and delay are mutually exclusive"}
+	}
+	if config.Schedule != "" {
+		if config.NotBefore != nil || config.Delay != 0 {
+			return &ConfigError{"schedule cannot be combined with not_before or delay"}
+		}
+		if len(config.DependsOn) > 0 {
+			return &ConfigError{"schedule cannot be combined with depends_on"}
+		}
+		if _, err := cron.ParseStandard(config.Schedule); err != nil {
+			return &ConfigError{fmt.Sprintf("invalid schedule: %s", err)}
+		}
+	}
+
+	return nil
+}
+
+// checkPermission returns PermissionError if caller in ctx has no permission
+// p, requests without identity are allowed.
+func checkPermission(ctx context.Context, p Permission) error {
+	id := IdentityFromContext(ctx)
+	if id == nil || id.Can(p) {
+		return nil
+	}
+	return &PermissionError{fmt.Sprintf("client %s has no %s permission", id.ClientID, p)}
+}
+
+// visible returns true if caller in ctx has access to tasks of client.
+func visible(ctx context.Context, clientID string) bool {
+	id := IdentityFromContext(ctx)
+	return id == nil || id.Owns(clientID)
+}
+
+// get returns task or schedule by id, tasks of other clients are not
+// returned unless caller has access to them.
+func (s *service) get(ctx context.Context, id TaskID) (*task, *schedule) {
 	s.tasksMu.RLock()
 	t := s.tasks[id]
+	sc := s.schedules[id]
 	s.tasksMu.RUnlock()
 
+	if t != nil && !visible(ctx, t.clientID) {
+		t = nil
+	}
+	if sc != nil && !visible(ctx, sc.config.ClientID) {
+		sc = nil
+	}
+
+	return t, sc
+}
+
+func (s *service) TaskStatus(ctx context.Context, id TaskID) (*TaskStatus, error) {
+	if err := checkPermission(ctx, PermissionStatus); err != nil {
+		return nil, err
+	}
+
+	t, sc := s.get(ctx, id)
+
+	if sc != nil {
+		return sc.status(), nil
+	}
+
 	if t == nil {
 		return nil, nil
 	}
@@ -72,16 +396,283 @@ func (s *service) TaskStatus(ctx context.Context, id TaskID) (*TaskStatus, error
 	return t.status(), nil
 }
 
-func (s *service) KillTask(ctx context.Context, id TaskID) (*TaskStatus, error) {
-	s.tasksMu.RLock()
-	t := s.tasks[id]
-	s.tasksMu.RUnlock()
+func (s *service) KillTask(ctx context.Context, id TaskID, opts KillOptions) (*TaskStatus, error) {
+	if err := checkPermission(ctx, PermissionKill); err != nil {
+		return nil, err
+	}
+
+	t, sc := s.get(ctx, id)
+
+	if sc != nil {
+		if len(opts.Addrs) > 0 {
+			return nil, &ConfigError{Msg: "recurring task cannot be killed partially"}
+		}
+		sc.kill(opts.Reason)
+		if !opts.Async {
+			<-sc.done
+		}
+		return sc.status(), nil
+	}
 
 	if t == nil {
 		return nil, nil
 	}
 
-	t.kill()
+	if len(opts.Addrs) == 0 {
+		killed := []*task{t}
+		if opts.Cascade {
+			killed = cascade(ctx, t)
+		}
+		// Dependents are killed first so that they are not killed for
+		// failed dependency.
+		for i := len(killed) - 1; i >= 0; i-- {
+			killed[i].kill(opts.Reason)
+		}
+		if !opts.Async {
+			for _, v := range killed {
+				<-v.done
+			}
+		}
+		return t.status(), nil
+	}
+
+	if opts.Cascade {
+		return nil, &ConfigError{Msg: "partial kill cannot be cascaded"}
+	}
+
+	if t.config.Mode == TwoPhase {
+		return nil, &ConfigError{Msg: "two_phase task cannot be killed partially"}
+	}
+
+	killed, err := t.killAddrs(opts.Addrs, opts.Reason)
+	if err != nil {
+		return nil, &ConfigError{Msg: err.Error()}
+	}
+	if !opts.Async {
+		for _, r := range killed {
+			<-r.finished
+		}
+	}
 
 	return t.status(), nil
 }
+
+// cascade returns t and tasks depending on it recursively, tasks not visible
+// to caller in ctx are skipped.
+func cascade(ctx context.Context, t *task) []*task {
+	var (
+		l    = []*task{t}
+		seen = map[TaskID]bool{t.ID(): true}
+	)
+	for i := 0; i < len(l); i++ {
+		for _, d := range l[i].dependentTasks() {
+			if !seen[d.ID()] && visible(ctx, d.clientID) {
+				seen[d.ID()] = true
+				l = append(l, d)
+			}
+		}
+	}
+	return l
+}
+
+func (s *service) PauseTask(ctx context.Context, id TaskID) (*TaskStatus, error) {
+	if err := checkPermission(ctx, PermissionKill); err != nil {
+		return nil, err
+	}
+
+	t, sc := s.get(ctx, id)
+
+	if sc != nil {
+		return nil, &ConfigError{Msg: "recurring task cannot be paused"}
+	}
+
+	if t == nil {
+		return nil, nil
+	}
+
+	if err := t.pause(); err != nil {
+		return nil, &ConfigError{Msg: err.Error()}
+	}
+
+	return t.status(), nil
+}
+
+func (s *service) ResumeTask(ctx context.Context, id TaskID) (*TaskStatus, error) {
+	if err := checkPermission(ctx, PermissionKill); err != nil {
+		return nil, err
+	}
+
+	t, sc := s.get(ctx, id)
+
+	if sc != nil {
+		return nil, &ConfigError{Msg: "recurring task cannot be paused"}
+	}
+
+	if t == nil {
+		return nil, nil
+	}
+
+	t.resume()
+
+	return t.status(), nil
+}
+
+// defaultRetryStatuses are statuses of calls retried by default.
+var defaultRetryStatuses = []Status{Failure, Killed, Ignored}
+
+func (s *service) RetryTask(ctx context.Context, id TaskID, opts RetryOptions) (TaskID, error) {
+	if err := checkPermission(ctx, PermissionCreate); err != nil {
+		return "", err
+	}
+
+	statuses := opts.Statuses
+	if len(statuses) == 0 {
+		statuses = defaultRetryStatuses
+	}
+	for _, v := range statuses {
+		switch v {
+		case Failure, Killed, Ignored:
+		default:
+			return "", &ConfigError{fmt.Sprintf("cannot retry %q results", v)}
+		}
+	}
+
+	t, sc := s.get(ctx, id)
+
+	if sc != nil {
+		return "", &ConfigError{Msg: "recurring task cannot be retried"}
+	}
+
+	if t == nil {
+		return "", nil
+	}
+
+	config, addrs, err := t.retryConfig(statuses)
+	if err != nil {
+		return "", &ConfigError{Msg: err.Error()}
+	}
+
+	r, err := s.startTask(ctx, config, addrs, t.ID(), nil)
+	if err == ErrShutdown {
+		return "", err
+	}
+	if err != nil {
+		return "", errors.New("failed to generate id")
+	}
+	t.addChild(r.ID())
+
+	log.Info(t.logger).Log(
+		"msg", "task retried",
+		"retry", r.ID(),
+		"addrs", len(addrs),
+	)
+
+	return r.ID(), nil
+}
+
+func (s *service) ListTasks(ctx context.Context) ([]*TaskStatus, error) {
+	if err := checkPermission(ctx, PermissionStatus); err != nil {
+		return nil, err
+	}
+
+	type entry struct {
+		created time.Time
+		status  *TaskStatus
+	}
+
+	s.tasksMu.RLock()
+	entries := make([]entry, 0, len(s.tasks)+len(s.schedules))
+	for _, t := range s.tasks {
+		if visible(ctx, t.clientID) {
+			entries = append(entries, entry{t.created, t.status()})
+		}
+	}
+	for _, sc := range s.schedules {
+		if visible(ctx, sc.config.ClientID) {
+			entries = append(entries, entry{sc.created, sc.status()})
+		}
+	}
+	s.tasksMu.RUnlock()
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].created.Before(entries[j].created)
+	})
+
+	l := make([]*TaskStatus, len(entries))
+	for i, e := range entries {
+		l[i] = e.status
+	}
+
+	return l, nil
+}
+
+// shutdownReason is kill reason of tasks killed on shutdown.
+const shutdownReason = "service shutdown"
+
+func (s *service) Shutdown(ctx context.Context) error {
+	s.tasksMu.Lock()
+	s.closing = true
+	tasks := make([]*task, 0, len(s.tasks))
+	for _, t := range s.tasks {
+		tasks = append(tasks, t)
+	}
+	schedules := make([]*schedule, 0, len(s.schedules))
+	for _, sc := range s.schedules {
+		schedules = append(schedules, sc)
+	}
+	s.tasksMu.Unlock()
+
+	for _, sc := range schedules {
+		sc.kill(shutdownReason)
+		<-sc.done
+	}
+
+	var running []*task
+	for _, t := range tasks {
+		switch t.state() {
+		case StateWaiting, StateScheduled, StatePaused:
+			t.kill(shutdownReason)
+			<-t.done
+			s.logFinalStatus(t)
+		case StateRunning:
+			running = append(running, t)
+		}
+	}
+
+	log.Info(s.logger).Log(
+		"msg", "waiting for tasks",
+		"running", len(running),
+	)
+
+	var err error
+	for _, t := range running {
+		select {
+		case <-t.done:
+		case <-ctx.Done():
+			err = ctx.Err()
+			t.kill(shutdownReason)
+			<-t.done
+		}
+		s.logFinalStatus(t)
+	}
+
+	return err
+}
+
+func (s *service) logFinalStatus(t *task) {
+	count := make(map[Status]int)
+	for _, r := range t.status().Results {
+		count[r.Status]++
+	}
+
+	keyvals := []interface{}{
+		"msg", "task final status",
+		"task", t.ID(),
+	}
+	for _, v := range []Status{Pending, Running, Success, Failure, Killed, Ignored, RolledBack, RollbackFailed} {
+		if count[v] > 0 {
+			keyvals = append(keyvals, string(v), count[v])
+		}
+	}
+	log.Info(s.logger).Log(keyvals...)
+}
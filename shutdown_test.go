@@ -0,0 +1,100 @@
+package proxy
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/golang/mock/gomock"
+)
+
+func TestServiceShutdown(t *testing.T) {
+	t.Parallel()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	m := NewMockRemoteClient(ctrl)
+	m.EXPECT().Update(gomock.Any(), "fast", "info").Return(nil).Do(func(ctx context.Context, addr, info string) {
+		time.Sleep(50 * time.Millisecond)
+	})
+	m.EXPECT().Update(gomock.Any(), "slow", "info").Return(context.Canceled).Do(func(ctx context.Context, addr, info string) {
+		<-ctx.Done()
+	})
+
+	s := NewService(m, []string{"fast"}, log.NewNopLogger()).(*service)
+	ctx := context.Background()
+
+	fast, err := s.CreateTask(ctx, &TaskConfig{Mode: Sequential, Info: "info"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.addrs = []string{"slow"}
+	slow, err := s.CreateTask(ctx, &TaskConfig{Mode: Sequential, Info: "info"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	scheduled, err := s.CreateTask(ctx, &TaskConfig{Mode: Sequential, Info: "info", Delay: Duration(time.Hour)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sctx, cancel := context.WithTimeout(ctx, 200*time.Millisecond)
+	defer cancel()
+	if err := s.Shutdown(sctx); err != context.DeadlineExceeded {
+		t.Fatal("expected deadline exceeded", err)
+	}
+
+	if _, err := s.CreateTask(ctx, &TaskConfig{Mode: Sequential, Info: "info"}); err != ErrShutdown {
+		t.Fatal("expected shutdown error", err)
+	}
+
+	for id, status := range map[TaskID]Status{
+		fast:      Success,
+		slow:      Killed,
+		scheduled: Ignored,
+	} {
+		st, _ := s.TaskStatus(ctx, id)
+		if st.State != StateDone || st.Results[0].Status != status {
+			t.Fatal("wrong status", st)
+		}
+	}
+}
+
+func TestServiceShutdownPaused(t *testing.T) {
+	t.Parallel()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	m := NewMockRemoteClient(ctrl)
+	m.EXPECT().Update(gomock.Any(), "addr0", "info").Return(nil).Do(func(ctx context.Context, addr, info string) {
+		close(started)
+		<-release
+	})
+
+	s := NewService(m, []string{"addr0", "addr1"}, log.NewNopLogger()).(*service)
+	ctx := context.Background()
+
+	id, err := s.CreateTask(ctx, &TaskConfig{Mode: Sequential, Info: "info"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-started
+	if _, err := s.PauseTask(ctx, id); err != nil {
+		t.Fatal(err)
+	}
+	close(release)
+
+	sctx, cancel := context.WithTimeout(ctx, time.Second)
+	defer cancel()
+	if err := s.Shutdown(sctx); err != nil {
+		t.Fatal(err)
+	}
+
+	st, _ := s.TaskStatus(ctx, id)
+	if st.State != StateDone || st.Results[0].Status != Success || st.Results[1].Status != Ignored {
+		t.Fatal("wrong status", st)
+	}
+}
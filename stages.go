@@ -0,0 +1,168 @@
+package proxy
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/mmatczuk/proxy/log"
+	"github.com/mmatczuk/proxy/trace"
+)
+
+// stepAddrs returns addresses called in step s.
+func stepAddrs(s Step, addrs []string) []string {
+	if len(s.Addrs) > 0 {
+		return s.Addrs
+	}
+	return addrs
+}
+
+// stepConfig returns configuration of remote calls of step s.
+func stepConfig(config *TaskConfig, s Step) *TaskConfig {
+	c := *config
+	c.Steps = nil
+	if s.Info != "" {
+		c.Info = s.Info
+	}
+	if s.Mode != "" {
+		c.Mode = s.Mode
+	}
+	c.FailOnError = config.FailOnError || s.FailOnError
+	return &c
+}
+
+// newStageResults returns results of all steps in order and indexes of
+// results of every step.
+func newStageResults(steps []Step, addrs []string) ([]*result, [][]int) {
+	var (
+		results []*result
+		stages  = make([][]int, len(steps))
+	)
+	for i, s := range steps {
+		for _, addr := range stepAddrs(s, addrs) {
+			r := newResult(addr)
+			r.Step = s.Name
+			stages[i] = append(stages[i], len(results))
+			results = append(results, r)
+		}
+	}
+	return results, stages
+}
+
+// runStages runs steps of a multi-step task in order, calls of a step are
+// made according to step mode. Next step is started only if the task was not
+// killed, failure of a step with FailOnError kills the task.
+func (t *task) runStages(ctx context.Context, config *TaskConfig, stages [][]int) {
+	for i, s := range config.Steps {
+		if t.killed() {
+			t.markPendingIgnored(t.reason())
+			return
+		}
+
+		t.setStep(s.Name)
+		log.Info(t.logger).Log(
+			"msg", "step started",
+			"step", s.Name,
+		)
+
+		c := stepConfig(config, s)
+		var steps [][]int
+		for _, step := range planSteps(c.Mode, len(stages[i])) {
+			v := make([]int, len(step))
+			for j, k := range step {
+				v[j] = stages[i][k]
+			}
+			steps = append(steps, v)
+		}
+
+		ctx, span := trace.Start(ctx, "step")
+		span.SetAttributes(
+			"step", s.Name,
+			"mode", c.Mode,
+		)
+		t.runSteps(ctx, c, steps)
+		span.End()
+	}
+}
+
+func (t *task) setStep(name string) {
+	t.mu.Lock()
+	t.step = name
+	t.mu.Unlock()
+}
+
+// validateSteps validates steps of config, step addresses must be a subset
+// of addrs.
+func validateSteps(config *TaskConfig, addrs []string) error {
+	if len(config.Steps) == 0 {
+		return nil
+	}
+
+	switch config.Mode {
+	case Sequential, Parallel:
+	default:
+		return &ConfigError{fmt.Sprintf("steps are not supported in %s mode", config.Mode)}
+	}
+	if config.CompensationInfo != "" {
+		return &ConfigError{"compensation_info is not supported with steps"}
+	}
+
+	names := make(map[string]bool)
+	for _, s := range config.Steps {
+		if s.Name == "" {
+			return &ConfigError{"missing step name"}
+		}
+		if names[s.Name] {
+			return &ConfigError{fmt.Sprintf("duplicate step %s", s.Name)}
+		}
+		names[s.Name] = true
+
+		switch s.Mode {
+		case "", Sequential, Parallel:
+		default:
+			return &ConfigError{fmt.Sprintf("step %s: unsupported mode %q", s.Name, s.Mode)}
+		}
+
+		seen := make(map[string]bool)
+		for _, addr := range s.Addrs {
+			if seen[addr] {
+				return &ConfigError{fmt.Sprintf("step %s: duplicate address %s", s.Name, addr)}
+			}
+			seen[addr] = true
+			if !contains(addrs, addr) {
+				return &ConfigError{fmt.Sprintf("step %s: unknown address %s", s.Name, addr)}
+			}
+		}
+	}
+
+	return nil
+}
+
+func contains(l []string, v string) bool {
+	for _, s := range l {
+		if s == v {
+			return true
+		}
+	}
+	return false
+}
+
+// planStages returns plan steps of a multi-step task.
+func planStages(config *TaskConfig, addrs []string) []PlanStep {
+	var l []PlanStep
+	for _, s := range config.Steps {
+		c := stepConfig(config, s)
+		a := stepAddrs(s, addrs)
+		for _, step := range planSteps(c.Mode, len(a)) {
+			var v PlanStep
+			for _, i := range step {
+				v.Calls = append(v.Calls, PlanCall{
+					Addr: a[i],
+					Info: c.Info,
+					Step: s.Name,
+				})
+			}
+			l = append(l, v)
+		}
+	}
+	return l
+}
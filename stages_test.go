@@ -0,0 +1,139 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/go-kit/kit/log"
+	"github.com/golang/mock/gomock"
+)
+
+var testSteps = []Step{
+	{Name: "disable", Info: "disable"},
+	{Name: "push", Addrs: []string{"addr1"}},
+	{Name: "enable", Info: "enable", Mode: Parallel},
+}
+
+func TestRunStagesTask(t *testing.T) {
+	t.Parallel()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	m := NewMockRemoteClient(ctrl)
+	push := m.EXPECT().Update(gomock.Any(), "addr1", "info").Return(nil).After(
+		m.EXPECT().Update(gomock.Any(), "addr1", "disable").Return(nil).After(
+			m.EXPECT().Update(gomock.Any(), "addr0", "disable").Return(nil),
+		),
+	)
+	m.EXPECT().Update(gomock.Any(), "addr0", "enable").Return(nil).After(push)
+	m.EXPECT().Update(gomock.Any(), "addr1", "enable").Return(errors.New("boom")).After(push)
+
+	task, err := newTask(context.Background(), &TaskConfig{
+		Mode:  Sequential,
+		Info:  "info",
+		Steps: testSteps,
+	}, m, []string{"addr0", "addr1"}, log.NewNopLogger())
+	if err != nil {
+		panic(err)
+	}
+
+	<-task.done
+
+	s := task.status()
+
+	if !reflect.DeepEqual(s, &TaskStatus{
+		ID:    task.ID(),
+		State: StateDone,
+		Step:  "enable",
+		Results: []Result{
+			{Addr: "addr0", Status: Success, Step: "disable"},
+			{Addr: "addr1", Status: Success, Step: "disable"},
+			{Addr: "addr1", Status: Success, Step: "push"},
+			{Addr: "addr0", Status: Success, Step: "enable"},
+			{Addr: "addr1", Status: Failure, Msg: "boom", Step: "enable"},
+		},
+	}) {
+		t.Fatal("wrong status", s)
+	}
+}
+
+func TestRunStagesTaskFailOnError(t *testing.T) {
+	t.Parallel()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	m := NewMockRemoteClient(ctrl)
+	m.EXPECT().Update(gomock.Any(), "addr0", "disable").Return(errors.New("boom"))
+
+	steps := append([]Step(nil), testSteps...)
+	steps[0].FailOnError = true
+
+	task, err := newTask(context.Background(), &TaskConfig{
+		Mode:  Sequential,
+		Info:  "info",
+		Steps: steps,
+	}, m, []string{"addr0", "addr1"}, log.NewNopLogger())
+	if err != nil {
+		panic(err)
+	}
+
+	<-task.done
+
+	s := task.status()
+
+	if !reflect.DeepEqual(s, &TaskStatus{
+		ID:    task.ID(),
+		State: StateDone,
+		Step:  "disable",
+		Results: []Result{
+			{Addr: "addr0", Status: Failure, Msg: "boom", Step: "disable"},
+			{Addr: "addr1", Status: Ignored, Step: "disable"},
+			{Addr: "addr1", Status: Ignored, Step: "push"},
+			{Addr: "addr0", Status: Ignored, Step: "enable"},
+			{Addr: "addr1", Status: Ignored, Step: "enable"},
+		},
+	}) {
+		t.Fatal("wrong status", s)
+	}
+}
+
+func TestServicePlanTaskSteps(t *testing.T) {
+	t.Parallel()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	s := NewService(NewMockRemoteClient(ctrl), []string{"addr0", "addr1"}, log.NewNopLogger())
+	ctx := context.Background()
+
+	p, err := s.PlanTask(ctx, &TaskConfig{Mode: Sequential, Info: "info", Steps: testSteps})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(p.Steps, []PlanStep{
+		{Calls: []PlanCall{{Addr: "addr0", Info: "disable", Step: "disable"}}},
+		{Calls: []PlanCall{{Addr: "addr1", Info: "disable", Step: "disable"}}},
+		{Calls: []PlanCall{{Addr: "addr1", Info: "info", Step: "push"}}},
+		{Calls: []PlanCall{{Addr: "addr0", Info: "enable", Step: "enable"}, {Addr: "addr1", Info: "enable", Step: "enable"}}},
+	}) {
+		t.Fatal("wrong plan", p)
+	}
+
+	table := []*TaskConfig{
+		{Mode: Quorum, Steps: testSteps},
+		{Mode: Sequential, Steps: testSteps, CompensationInfo: "undo"},
+		{Mode: Sequential, Steps: []Step{{Info: "info"}}},
+		{Mode: Sequential, Steps: []Step{{Name: "a"}, {Name: "a"}}},
+		{Mode: Sequential, Steps: []Step{{Name: "a", Mode: Race}}},
+		{Mode: Sequential, Steps: []Step{{Name: "a", Addrs: []string{"addr2"}}}},
+		{Mode: Sequential, Steps: []Step{{Name: "a", Addrs: []string{"addr0", "addr0"}}}},
+	}
+
+	for i, config := range table {
+		_, err := s.PlanTask(ctx, config)
+		if _, ok := err.(*ConfigError); !ok {
+			t.Fatal(i, "expected config error", err)
+		}
+	}
+}
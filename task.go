@@ -2,21 +2,70 @@ package proxy
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/mmatczuk/proxy/log"
+	"github.com/mmatczuk/proxy/trace"
 )
 
+// rollbackTimeout limits compensation of a task and abort of a two-phase
+// task, they outlive the task context so that they are not cancelled by kill.
+const rollbackTimeout = 30 * time.Second
+
+// rollbackContext returns context of compensation and abort calls.
+func rollbackContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.WithoutCancel(ctx), rollbackTimeout)
+}
+
 // result extends Result with a mutex to protect it's state.
 type result struct {
 	Result
+	// cancel cancels running remote call.
+	cancel context.CancelFunc
+	// killed is set if the call was killed individually, killReason is the
+	// reason given.
+	killed     bool
+	killReason string
+	// finished is closed when result reaches final status, for two-phase
+	// tasks it's closed when the first phase ends.
+	finished chan struct{}
+	closed   bool
 	// mu protects result
 	mu sync.RWMutex
 }
 
-func (r *result) setStatus(s Status, err error) {
+func newResult(addr string) *result {
+	return &result{
+		Result: Result{
+			Addr:   addr,
+			Status: Pending,
+		},
+		finished: make(chan struct{}),
+	}
+}
+
+// start marks result as running, it returns false if the call was killed
+// before it started.
+func (r *result) start(cancel context.CancelFunc) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.Status != Pending {
+		return false
+	}
+	r.Status = Running
+	r.cancel = cancel
+	return true
+}
+
+// finish sets final status of the result, for killed results reason is used
+// unless the call was killed individually.
+func (r *result) finish(s Status, err error, reason string) {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
@@ -24,12 +73,90 @@ func (r *result) setStatus(s Status, err error) {
 	if err != nil {
 		r.Msg = err.Error()
 	}
+	if s == Killed || s == Ignored {
+		if r.killed {
+			reason = r.killReason
+		}
+		r.KillReason = reason
+	}
+	r.cancel = nil
+	r.close()
+}
+
+func (r *result) close() {
+	if !r.closed {
+		close(r.finished)
+		r.closed = true
+	}
+}
+
+// kill kills the call individually, pending call is marked as killed and
+// running call is cancelled.
+func (r *result) kill(reason string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	switch r.Status {
+	case Pending:
+		r.Status = Killed
+		r.KillReason = reason
+		r.close()
+	case Running:
+		r.killed = true
+		r.killReason = reason
+		r.cancel()
+	}
+}
+
+// ignore marks pending result as ignored.
+func (r *result) ignore(reason string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.Status == Pending {
+		r.Status = Ignored
+		r.KillReason = reason
+		r.close()
+	}
+}
+
+// rollback sets status of compensation call.
+func (r *result) rollback(err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err != nil {
+		r.Status = RollbackFailed
+		r.Msg = err.Error()
+		return
+	}
+	r.Status = RolledBack
+}
+
+func (r *result) status() Status {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.Status
+}
+
+func (r *result) isKilled() bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.killed
 }
 
 // task runs remote tasks and stores the results.
 type task struct {
 	// id is task identifier.
 	id TaskID
+	// clientID is identifier of the client that owns the task.
+	clientID string
+	// requestID is identifier of the request that created the task.
+	requestID string
+	// config is configuration the task was created with.
+	config TaskConfig
+	// parentID is identifier of the task this task retries.
+	parentID TaskID
 	// context is a common context for all remote calls.
 	context context.Context
 	// cancel enables cancelling remote calls.
@@ -38,106 +165,358 @@ type task struct {
 	client RemoteClient
 	// results contains remote call results.
 	results []*result
+	// created is task creation time.
+	created time.Time
+	// deps are tasks this task depends on.
+	deps []*task
+	// ready is closed when dependencies are met.
+	ready chan struct{}
+	// startAt is time when remote calls shall start, zero value means
+	// immediately.
+	startAt time.Time
+	// started is closed when remote calls are started.
+	started chan struct{}
 	// done is closed when task is done
 	done chan struct{}
+	// killReason is the reason given when the task was killed.
+	killReason string
+	// resumed is not nil while task is paused, it's closed on resume.
+	resumed chan struct{}
+	// children contains identifiers of tasks retrying this task.
+	children []TaskID
+	// verdict is overall outcome of quorum or race task.
+	verdict Verdict
+	// dependents are tasks depending on this task.
+	dependents []*task
+	// step is name of the current step of multi-step task.
+	step string
+	// mu protects killReason, resumed, children, verdict, dependents and
+	// step
+	mu sync.Mutex
 	// logger
 	logger log.Logger
 }
 
-// newTask creates new task and calls remote systems based on configuration.
-func newTask(config *TaskConfig, client RemoteClient, addrs []string, logger log.Logger) (*task, error) {
+// newTask creates new task and calls remote systems based on configuration,
+// if configuration specifies start time the calls are delayed until then.
+// Task outlives ctx, only ctx values such as trace span are retained.
+func newTask(ctx context.Context, config *TaskConfig, client RemoteClient, addrs []string, logger log.Logger) (*task, error) {
+	return newDependentTask(ctx, config, client, addrs, nil, logger)
+}
+
+// newDependentTask works like newTask, remote calls are delayed until tasks
+// in deps are done.
+func newDependentTask(ctx context.Context, config *TaskConfig, client RemoteClient, addrs []string, deps []*task, logger log.Logger) (*task, error) {
 	u, err := uuid.NewUUID()
 	if err != nil {
 		return nil, err
 	}
 
+	now := time.Now()
 	t := &task{
-		id:      TaskID(u.String()),
-		client:  client,
-		results: make([]*result, len(addrs), len(addrs)),
-		done:    make(chan struct{}),
-		logger:  logger,
+		id:        TaskID(u.String()),
+		clientID:  config.ClientID,
+		requestID: RequestIDFromContext(ctx),
+		config:    *config,
+		client:    client,
+		deps:      deps,
+		ready:     make(chan struct{}),
+		created:   now,
+		startAt:   startTime(config, now),
+		started:   make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+	t.logger = log.With(logger, "task", t.id, "client", config.ClientID)
+	if t.requestID != "" {
+		t.logger = log.With(t.logger, "request_id", t.requestID)
 	}
-	t.context, t.cancel = context.WithCancel(context.Background())
+	t.context, t.cancel = context.WithCancel(context.WithoutCancel(ctx))
 
-	for i, addr := range addrs {
-		t.results[i] = &result{
-			Result: Result{
-				Addr:   addr,
-				Status: Pending,
-			},
+	var stages [][]int
+	if len(config.Steps) > 0 {
+		t.results, stages = newStageResults(config.Steps, addrs)
+	} else {
+		t.results = make([]*result, len(addrs), len(addrs))
+		for i, addr := range addrs {
+			t.results[i] = newResult(addr)
 		}
 	}
 
-	switch config.Mode {
-	case Sequential:
-		go t.runSequential(config, addrs)
-	case Parallel:
-		go t.runParallel(config, addrs)
+	var run func(ctx context.Context, config *TaskConfig)
+	switch {
+	case len(config.Steps) > 0:
+		run = func(ctx context.Context, config *TaskConfig) {
+			t.runStages(ctx, config, stages)
+		}
+	case config.Mode == TwoPhase:
+		run = t.runTwoPhase
+	case config.Mode == Quorum:
+		run = t.runQuorum
+	case config.Mode == Race:
+		run = t.runRace
 	default:
-		panic("not supported mode")
+		steps := planSteps(config.Mode, len(addrs))
+		run = func(ctx context.Context, config *TaskConfig) {
+			t.runSteps(ctx, config, steps)
+		}
+	}
+
+	if len(deps) == 0 {
+		close(t.ready)
 	}
+	if t.startAt.IsZero() {
+		close(t.started)
+	}
+
+	go t.waitAndRun(run, config)
 
 	return t, nil
 }
 
-func (t *task) runSequential(config *TaskConfig, addrs []string) {
+// planSteps returns indexes of addresses grouped in steps, steps are executed
+// in order and calls within a step concurrently.
+func planSteps(mode TaskMode, n int) [][]int {
+	switch mode {
+	case Sequential, Race:
+		steps := make([][]int, n)
+		for i := range steps {
+			steps[i] = []int{i}
+		}
+		return steps
+	case Parallel, Quorum:
+		step := make([]int, n)
+		for i := range step {
+			step[i] = i
+		}
+		return [][]int{step}
+	default:
+		panic("not supported mode")
+	}
+}
+
+// startTime returns time when task execution shall start, zero value means
+// immediately.
+func startTime(config *TaskConfig, now time.Time) time.Time {
+	if config.NotBefore != nil {
+		return *config.NotBefore
+	}
+	if config.Delay > 0 {
+		return now.Add(time.Duration(config.Delay))
+	}
+	return time.Time{}
+}
+
+// waitAndRun waits until dependencies are met and task start time and runs
+// it, if task is killed before it starts all results are marked as ignored.
+func (t *task) waitAndRun(run func(ctx context.Context, config *TaskConfig), config *TaskConfig) {
 	defer t.cancel()
 	defer close(t.done)
 
-	for i, addr := range addrs {
-		err := t.remoteCall(config, addr, t.results[i])
-		if t.killed() || (err != nil && config.FailOnError) {
-			t.markPendingIgnored()
-			break
+	if len(t.deps) > 0 {
+		if !t.waitDeps() {
+			t.markPendingIgnored(t.reason())
+			return
+		}
+		close(t.ready)
+	}
+	if !t.startAt.IsZero() {
+		if !t.wait() {
+			t.markPendingIgnored(t.reason())
+			return
 		}
+		close(t.started)
+	}
+
+	tasksRunning.Inc()
+	defer tasksRunning.Dec()
+
+	ctx, span := trace.Start(t.context, "task")
+	span.SetAttributes(
+		"task", t.id,
+		"mode", config.Mode,
+	)
+	defer span.End()
+
+	run(ctx, config)
+
+	// failure of a call with FailOnError kills the task
+	if config.CompensationInfo != "" && t.killed() && t.cutShort(config) {
+		ctx, cancel := rollbackContext(ctx)
+		t.compensate(ctx, config)
+		cancel()
 	}
 }
 
-func (t *task) markPendingIgnored() {
+// cutShort returns true if not all addresses were called because the task
+// was killed or a call failed with FailOnError.
+func (t *task) cutShort(config *TaskConfig) bool {
 	for _, r := range t.results {
-		r.mu.Lock()
-		if r.Status == Pending {
-			r.Status = Ignored
+		switch r.status() {
+		case Pending, Ignored, Killed:
+			return true
+		case Failure:
+			if config.FailOnError {
+				return true
+			}
 		}
-		r.mu.Unlock()
 	}
+	return false
 }
 
-func (t *task) runParallel(config *TaskConfig, addrs []string) {
-	defer t.cancel()
-	defer close(t.done)
+// compensate sends compensation info to addresses that were updated
+// successfully, in reverse order. It's called when task is killed before all
+// calls succeeded or fails with FailOnError.
+func (t *task) compensate(ctx context.Context, config *TaskConfig) {
+	ctx, span := trace.Start(ctx, "compensate")
+	defer span.End()
+
+	for i := len(t.results) - 1; i >= 0; i-- {
+		r := t.results[i]
+		if r.status() != Success {
+			continue
+		}
+
+		err := t.client.Update(ctx, r.Addr, config.CompensationInfo)
+		r.rollback(err)
+		if err != nil {
+			remoteCalls.WithLabelValues(r.Addr, RollbackFailed).Inc()
+			log.Error(t.logger).Log(
+				"msg", "rollback failure",
+				"addr", r.Addr,
+				"err", err,
+			)
+			continue
+		}
+		remoteCalls.WithLabelValues(r.Addr, RolledBack).Inc()
+
+		log.Info(t.logger).Log(
+			"msg", "rolled back",
+			"addr", r.Addr,
+		)
+	}
+}
 
-	var wg sync.WaitGroup
-	for i, addr := range addrs {
-		i, addr := i, addr
+// wait blocks until task start time, it returns false if task was killed
+// in the meantime.
+func (t *task) wait() bool {
+	log.Info(t.logger).Log(
+		"msg", "task scheduled",
+		"start_at", t.startAt,
+	)
+
+	timer := time.NewTimer(time.Until(t.startAt))
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return true
+	case <-t.context.Done():
+		return false
+	}
+}
+
+// runSteps calls addresses step by step, before every step it waits while
+// the task is paused. Pending calls are ignored if the task is killed or a
+// call fails and FailOnError is set.
+func (t *task) runSteps(ctx context.Context, config *TaskConfig, steps [][]int) {
+	for _, step := range steps {
+		if !t.waitResumed() {
+			t.markPendingIgnored(t.reason())
+			break
+		}
+		failed := t.runStep(ctx, config, step)
+		if t.killed() || (failed && config.FailOnError) {
+			t.markPendingIgnored(t.reason())
+			break
+		}
+	}
+}
+
+// runStep calls addresses concurrently, it returns true if any call failed.
+func (t *task) runStep(ctx context.Context, config *TaskConfig, step []int) bool {
+	var (
+		wg     sync.WaitGroup
+		mu     sync.Mutex
+		failed bool
+	)
+	for _, i := range step {
+		r := t.results[i]
 		wg.Add(1)
 		go func() {
-			t.remoteCall(config, addr, t.results[i])
+			if err := t.remoteCall(ctx, config, r.Addr, r); err != nil {
+				mu.Lock()
+				failed = true
+				mu.Unlock()
+			}
 			wg.Done()
 		}()
 	}
 	wg.Wait()
+	return failed
+}
+
+func (t *task) markPendingIgnored(reason string) {
+	for _, r := range t.results {
+		r.ignore(reason)
+	}
+}
+
+// remoteCall calls addr and sets result, calls killed individually are not
+// reported as errors.
+func (t *task) remoteCall(ctx context.Context, config *TaskConfig, addr string, r *result) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	if !r.start(cancel) {
+		return nil
+	}
+
+	return t.update(ctx, config, r, config.Info)
 }
 
-func (t *task) remoteCall(config *TaskConfig, addr string, r *result) error {
-	r.setStatus(Running, nil)
+// update calls remote system and sets result, result must be started.
+func (t *task) update(ctx context.Context, config *TaskConfig, r *result, info string) error {
+	addr := r.Addr
+
+	ctx, span := trace.Start(ctx, "remoteCall")
+	defer span.End()
+	span.SetAttributes("addr", addr)
+	if p := PhaseFromContext(ctx); p != "" {
+		span.SetAttributes("phase", p)
+	}
+
+	remoteCallsInFlight.Inc()
+	start := time.Now()
+	err := t.client.Update(ctx, addr, info)
+	remoteCallDuration.WithLabelValues(addr).Observe(time.Since(start).Seconds())
+	remoteCallsInFlight.Dec()
 
-	err := t.client.Update(t.context, addr, config.Info)
 	if err != nil {
-		if contextCanceledError(err) {
-			r.setStatus(Killed, nil)
+		if errors.Is(ctx.Err(), context.Canceled) || contextCanceledError(err) {
+			r.finish(Killed, nil, t.reason())
+			remoteCalls.WithLabelValues(addr, Killed).Inc()
+			span.SetAttributes("outcome", Killed)
 		} else {
-			r.setStatus(Failure, err)
+			r.finish(Failure, err, "")
+			remoteCalls.WithLabelValues(addr, Failure).Inc()
+			span.SetAttributes("outcome", Failure)
+		}
+		span.SetError(err)
+
+		if r.isKilled() {
+			log.Info(t.logger).Log(
+				"msg", "remote call killed",
+				"addr", addr,
+			)
+			return nil
 		}
 
 		if config.FailOnError {
 			t.cancel()
 		}
 
-		t.logger.Log(
+		log.Warn(t.logger).Log(
 			"msg", "remote call failure",
-			"task", t.id,
 			"addr", addr,
 			"err", err,
 		)
@@ -145,11 +524,12 @@ func (t *task) remoteCall(config *TaskConfig, addr string, r *result) error {
 		return err
 	}
 
-	r.setStatus(Success, nil)
+	r.finish(Success, nil, "")
+	remoteCalls.WithLabelValues(addr, Success).Inc()
+	span.SetAttributes("outcome", Success)
 
-	t.logger.Log(
+	log.Debug(t.logger).Log(
 		"msg", "remote call success",
-		"task", t.id,
 		"addr", addr,
 	)
 
@@ -165,10 +545,54 @@ func (t *task) ID() TaskID {
 	return t.id
 }
 
+func (t *task) state() TaskState {
+	select {
+	case <-t.done:
+		return StateDone
+	default:
+	}
+
+	select {
+	case <-t.ready:
+	default:
+		return StateWaiting
+	}
+
+	select {
+	case <-t.started:
+	default:
+		return StateScheduled
+	}
+
+	if t.paused() {
+		return StatePaused
+	}
+	return StateRunning
+}
+
 func (t *task) status() *TaskStatus {
 	s := TaskStatus{
-		Results: make([]Result, len(t.results), len(t.results)),
+		ID:         t.id,
+		ClientID:   t.clientID,
+		State:      t.state(),
+		RequestID:  t.requestID,
+		ParentID:   t.parentID,
+		DependsOn:  append([]TaskID(nil), t.config.DependsOn...),
+		KillReason: t.reason(),
+		Results:    make([]Result, len(t.results), len(t.results)),
 	}
+	if s.State == StateScheduled {
+		startAt := t.startAt
+		s.StartAt = &startAt
+	}
+
+	t.mu.Lock()
+	if len(t.children) > 0 {
+		s.Children = append([]TaskID(nil), t.children...)
+	}
+	s.Verdict = t.verdict
+	s.Step = t.step
+	t.mu.Unlock()
 
 	for i, r := range t.results {
 		r.mu.RLock()
@@ -183,7 +607,183 @@ func (t *task) killed() bool {
 	return t.context.Err() != nil
 }
 
-func (t *task) kill() {
+// kill cancels all remote calls, it does not wait for the task to finish.
+// Reason is recorded only if the task is not done yet.
+func (t *task) kill(reason string) {
+	t.mu.Lock()
+	select {
+	case <-t.done:
+	default:
+		if t.killReason == "" {
+			t.killReason = reason
+		}
+	}
+	t.mu.Unlock()
+
+	log.Info(t.logger).Log(
+		"msg", "task killed",
+		"reason", reason,
+	)
+
 	t.cancel()
-	<-t.done
+}
+
+// killAddrs kills calls to the given addresses, it returns results of the
+// killed calls.
+func (t *task) killAddrs(addrs []string, reason string) ([]*result, error) {
+	var killed []*result
+	for _, addr := range addrs {
+		var found bool
+		for _, r := range t.results {
+			if r.Addr == addr {
+				killed = append(killed, r)
+				found = true
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("unknown address %s", addr)
+		}
+	}
+
+	log.Info(t.logger).Log(
+		"msg", "remote calls killed",
+		"addrs", strings.Join(addrs, ","),
+		"reason", reason,
+	)
+
+	for _, r := range killed {
+		r.kill(reason)
+	}
+
+	return killed, nil
+}
+
+// pause stops dispatching new remote calls, calls in flight are not affected.
+// Only sequential tasks can be paused.
+func (t *task) pause() error {
+	if t.config.Mode != Sequential {
+		return fmt.Errorf("%s task cannot be paused", t.config.Mode)
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	select {
+	case <-t.done:
+		return nil
+	default:
+	}
+
+	if t.resumed == nil {
+		t.resumed = make(chan struct{})
+		log.Info(t.logger).Log("msg", "task paused")
+	}
+	return nil
+}
+
+// resume resumes paused task.
+func (t *task) resume() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.resumed != nil {
+		close(t.resumed)
+		t.resumed = nil
+		log.Info(t.logger).Log("msg", "task resumed")
+	}
+}
+
+func (t *task) paused() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.resumed != nil
+}
+
+// waitResumed blocks while task is paused, it returns false if task was
+// killed in the meantime.
+func (t *task) waitResumed() bool {
+	t.mu.Lock()
+	resumed := t.resumed
+	t.mu.Unlock()
+
+	if resumed == nil {
+		return true
+	}
+
+	select {
+	case <-resumed:
+		return !t.killed()
+	case <-t.context.Done():
+		return false
+	}
+}
+
+// retryConfig returns configuration and addresses of a task retrying calls
+// that ended with one of the statuses, the task must be done.
+func (t *task) retryConfig(statuses []Status) (*TaskConfig, []string, error) {
+	if t.state() != StateDone {
+		return nil, nil, fmt.Errorf("task is not done")
+	}
+	if len(t.config.Steps) > 0 {
+		return nil, nil, fmt.Errorf("multi-step task cannot be retried")
+	}
+
+	var (
+		addrs     []string
+		succeeded int
+	)
+	for _, r := range t.results {
+		s := r.status()
+		if s == Success {
+			succeeded++
+		}
+		for _, v := range statuses {
+			if s == v {
+				addrs = append(addrs, r.Addr)
+				break
+			}
+		}
+	}
+	if len(addrs) == 0 {
+		return nil, nil, fmt.Errorf("no results to retry")
+	}
+
+	config := t.config
+	config.NotBefore = nil
+	config.Delay = 0
+	config.DependsOn = nil
+	config.Condition = ""
+	// Required is rescaled to the retried addresses, calls that already
+	// succeeded count towards it.
+	if config.Required != 0 {
+		n := config.Required.count(len(t.results)) - succeeded
+		if n < 1 {
+			n = 1
+		}
+		if n > len(addrs) {
+			n = len(addrs)
+		}
+		config.Required = Threshold(n)
+	}
+
+	return &config, addrs, nil
+}
+
+func (t *task) setVerdict(v Verdict) {
+	t.mu.Lock()
+	t.verdict = v
+	t.mu.Unlock()
+}
+
+// addChild records identifier of a task retrying this task.
+func (t *task) addChild(id TaskID) {
+	t.mu.Lock()
+	t.children = append(t.children, id)
+	t.mu.Unlock()
+}
+
+func (t *task) reason() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.killReason
 }
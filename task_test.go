@@ -4,10 +4,13 @@ import (
 	"context"
 	"errors"
 	"reflect"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/go-kit/kit/log"
 	"github.com/golang/mock/gomock"
+	"github.com/mmatczuk/proxy/trace"
 )
 
 func TestRunSequentialTaskFailOnError(t *testing.T) {
@@ -21,7 +24,7 @@ func TestRunSequentialTaskFailOnError(t *testing.T) {
 		m.EXPECT().Update(gomock.Any(), "addr1", "info").Return(errors.New("boom")),
 	)
 
-	task, err := newTask(&TaskConfig{
+	task, err := newTask(context.Background(), &TaskConfig{
 		Mode:        Sequential,
 		FailOnError: true,
 		Info:        "info",
@@ -30,11 +33,13 @@ func TestRunSequentialTaskFailOnError(t *testing.T) {
 		panic(err)
 	}
 
-	<-task.context.Done()
+	<-task.done
 
 	s := task.status()
 
 	if !reflect.DeepEqual(s, &TaskStatus{
+		ID:    task.ID(),
+		State: StateDone,
 		Results: []Result{
 			{
 				Addr:   "addr0",
@@ -67,7 +72,7 @@ func TestRunSequentialTask(t *testing.T) {
 		m.EXPECT().Update(gomock.Any(), "addr2", "info").Return(errors.New("boom")),
 	)
 
-	task, err := newTask(&TaskConfig{
+	task, err := newTask(context.Background(), &TaskConfig{
 		Mode:        Sequential,
 		FailOnError: false,
 		Info:        "info",
@@ -81,6 +86,8 @@ func TestRunSequentialTask(t *testing.T) {
 	s := task.status()
 
 	if !reflect.DeepEqual(s, &TaskStatus{
+		ID:    task.ID(),
+		State: StateDone,
 		Results: []Result{
 			{
 				Addr:   "addr0",
@@ -110,7 +117,7 @@ func TestRunSequentialTaskKill(t *testing.T) {
 	m := NewMockRemoteClient(ctrl)
 	m.EXPECT().Update(gomock.Any(), "addr0", "info").Return(context.Canceled).Do(func(ctx context.Context, addr, info string) { <-ctx.Done() })
 
-	task, err := newTask(&TaskConfig{
+	task, err := newTask(context.Background(), &TaskConfig{
 		Mode:        Sequential,
 		FailOnError: false,
 		Info:        "info",
@@ -119,13 +126,15 @@ func TestRunSequentialTaskKill(t *testing.T) {
 		panic(err)
 	}
 
-	task.kill()
+	task.kill("")
 
 	<-task.done
 
 	s := task.status()
 
 	if !reflect.DeepEqual(s, &TaskStatus{
+		ID:    task.ID(),
+		State: StateDone,
 		Results: []Result{
 			{
 				Addr:   "addr0",
@@ -155,7 +164,7 @@ func TestRunParallelTaskFailOnError(t *testing.T) {
 	m.EXPECT().Update(gomock.Any(), "addr1", "info").Return(errors.New("boom"))
 	m.EXPECT().Update(gomock.Any(), "addr2", "info").Return(context.Canceled).Do(func(ctx context.Context, addr, info string) { <-ctx.Done() })
 
-	task, err := newTask(&TaskConfig{
+	task, err := newTask(context.Background(), &TaskConfig{
 		Mode:        Parallel,
 		FailOnError: true,
 		Info:        "info",
@@ -169,6 +178,8 @@ func TestRunParallelTaskFailOnError(t *testing.T) {
 	s := task.status()
 
 	if !reflect.DeepEqual(s, &TaskStatus{
+		ID:    task.ID(),
+		State: StateDone,
 		Results: []Result{
 			{
 				Addr:   "addr0",
@@ -199,7 +210,7 @@ func TestRunParallelTask(t *testing.T) {
 	m.EXPECT().Update(gomock.Any(), "addr1", "info").Return(errors.New("boom"))
 	m.EXPECT().Update(gomock.Any(), "addr2", "info").Return(nil)
 
-	task, err := newTask(&TaskConfig{
+	task, err := newTask(context.Background(), &TaskConfig{
 		Mode:        Parallel,
 		FailOnError: false,
 		Info:        "info",
@@ -213,6 +224,8 @@ func TestRunParallelTask(t *testing.T) {
 	s := task.status()
 
 	if !reflect.DeepEqual(s, &TaskStatus{
+		ID:    task.ID(),
+		State: StateDone,
 		Results: []Result{
 			{
 				Addr:   "addr0",
@@ -232,3 +245,526 @@ func TestRunParallelTask(t *testing.T) {
 		t.Fatal("wrong status", s)
 	}
 }
+
+func TestRunTaskRequestID(t *testing.T) {
+	t.Parallel()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	m := NewMockRemoteClient(ctrl)
+	m.EXPECT().Update(gomock.Any(), "addr0", "info").Return(nil).Do(func(ctx context.Context, addr, info string) {
+		if RequestIDFromContext(ctx) != "test-id" {
+			t.Error("wrong request id", RequestIDFromContext(ctx))
+		}
+	})
+
+	task, err := newTask(WithRequestID(context.Background(), "test-id"), &TaskConfig{
+		Mode: Sequential,
+		Info: "info",
+	}, m, []string{"addr0"}, log.NewNopLogger())
+	if err != nil {
+		panic(err)
+	}
+
+	<-task.done
+
+	if s := task.status(); s.RequestID != "test-id" {
+		t.Fatal("wrong status", s)
+	}
+}
+
+func TestRunDelayedTask(t *testing.T) {
+	t.Parallel()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	m := NewMockRemoteClient(ctrl)
+	m.EXPECT().Update(gomock.Any(), "addr0", "info").Return(nil)
+
+	task, err := newTask(context.Background(), &TaskConfig{
+		Mode:  Sequential,
+		Info:  "info",
+		Delay: Duration(50 * time.Millisecond),
+	}, m, []string{"addr0"}, log.NewNopLogger())
+	if err != nil {
+		panic(err)
+	}
+
+	s := task.status()
+	if s.State != StateScheduled || s.StartAt == nil || s.Results[0].Status != Pending {
+		t.Fatal("wrong status", s)
+	}
+
+	<-task.done
+
+	s = task.status()
+
+	if !reflect.DeepEqual(s, &TaskStatus{
+		ID:    task.ID(),
+		State: StateDone,
+		Results: []Result{
+			{
+				Addr:   "addr0",
+				Status: Success,
+			},
+		},
+	}) {
+		t.Fatal("wrong status", s)
+	}
+}
+
+func TestRunDelayedTaskKill(t *testing.T) {
+	t.Parallel()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	m := NewMockRemoteClient(ctrl)
+
+	notBefore := time.Now().Add(time.Hour)
+	task, err := newTask(context.Background(), &TaskConfig{
+		Mode:      Parallel,
+		Info:      "info",
+		NotBefore: &notBefore,
+	}, m, []string{"addr0", "addr1"}, log.NewNopLogger())
+	if err != nil {
+		panic(err)
+	}
+
+	task.kill("")
+	<-task.done
+
+	s := task.status()
+
+	if !reflect.DeepEqual(s, &TaskStatus{
+		ID:    task.ID(),
+		State: StateDone,
+		Results: []Result{
+			{
+				Addr:   "addr0",
+				Status: Ignored,
+			},
+			{
+				Addr:   "addr1",
+				Status: Ignored,
+			},
+		},
+	}) {
+		t.Fatal("wrong status", s)
+	}
+}
+
+type recordingExporter struct {
+	spans []*trace.SpanData
+	mu    sync.Mutex
+}
+
+func (e *recordingExporter) Export(s *trace.SpanData) error {
+	e.mu.Lock()
+	e.spans = append(e.spans, s)
+	e.mu.Unlock()
+	return nil
+}
+
+func TestRunTaskTrace(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	e := &recordingExporter{}
+	trace.SetExporter(e)
+	defer trace.SetExporter(nil)
+
+	m := NewMockRemoteClient(ctrl)
+	m.EXPECT().Update(gomock.Any(), "addr0", "info").Return(nil)
+	m.EXPECT().Update(gomock.Any(), "addr1", "info").Return(errors.New("boom"))
+
+	ctx, root := trace.Start(context.Background(), "root")
+	task, err := newTask(ctx, &TaskConfig{
+		Mode: Parallel,
+		Info: "info",
+	}, m, []string{"addr0", "addr1"}, log.NewNopLogger())
+	if err != nil {
+		panic(err)
+	}
+	root.End()
+
+	<-task.done
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if len(e.spans) != 4 {
+		t.Fatal("wrong number of spans", e.spans)
+	}
+	spans := make(map[string]*trace.SpanData)
+	for _, s := range e.spans {
+		if s.Name == "remoteCall" {
+			spans[s.Attributes["addr"].(string)] = s
+		} else {
+			spans[s.Name] = s
+		}
+	}
+	if *spans["task"].ParentID != spans["root"].SpanID {
+		t.Fatal("wrong task span parent", spans["task"])
+	}
+	if *spans["addr0"].ParentID != spans["task"].SpanID || spans["addr0"].Attributes["outcome"] != Success {
+		t.Fatal("wrong remote call span", spans["addr0"])
+	}
+	if spans["addr1"].Attributes["outcome"] != Failure || spans["addr1"].Error != "boom" {
+		t.Fatal("wrong remote call span", spans["addr1"])
+	}
+}
+
+func TestRunParallelTaskKillAddrs(t *testing.T) {
+	t.Parallel()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	started := make(chan struct{})
+	m := NewMockRemoteClient(ctrl)
+	m.EXPECT().Update(gomock.Any(), "addr0", "info").Return(context.Canceled).Do(func(ctx context.Context, addr, info string) {
+		close(started)
+		<-ctx.Done()
+	})
+	m.EXPECT().Update(gomock.Any(), "addr1", "info").Return(nil)
+
+	task, err := newTask(context.Background(), &TaskConfig{
+		Mode:        Parallel,
+		FailOnError: true,
+		Info:        "info",
+	}, m, []string{"addr0", "addr1"}, log.NewNopLogger())
+	if err != nil {
+		panic(err)
+	}
+
+	if _, err := task.killAddrs([]string{"addr2"}, "test"); err == nil {
+		t.Fatal("expected error")
+	}
+
+	<-started
+	killed, err := task.killAddrs([]string{"addr0"}, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, r := range killed {
+		<-r.finished
+	}
+
+	<-task.done
+
+	s := task.status()
+
+	if !reflect.DeepEqual(s, &TaskStatus{
+		ID:    task.ID(),
+		State: StateDone,
+		Results: []Result{
+			{
+				Addr:       "addr0",
+				Status:     Killed,
+				KillReason: "test",
+			},
+			{
+				Addr:   "addr1",
+				Status: Success,
+			},
+		},
+	}) {
+		t.Fatal("wrong status", s)
+	}
+}
+
+func TestRunSequentialTaskPause(t *testing.T) {
+	t.Parallel()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	m := NewMockRemoteClient(ctrl)
+	m.EXPECT().Update(gomock.Any(), "addr0", "info").Return(nil).Do(func(ctx context.Context, addr, info string) {
+		close(started)
+		<-release
+	})
+	m.EXPECT().Update(gomock.Any(), "addr1", "info").Return(nil)
+
+	task, err := newTask(context.Background(), &TaskConfig{
+		Mode: Sequential,
+		Info: "info",
+	}, m, []string{"addr0", "addr1"}, log.NewNopLogger())
+	if err != nil {
+		panic(err)
+	}
+
+	<-started
+	if err := task.pause(); err != nil {
+		t.Fatal(err)
+	}
+	close(release)
+	<-task.results[0].finished
+
+	s := task.status()
+
+	if !reflect.DeepEqual(s, &TaskStatus{
+		ID:    task.ID(),
+		State: StatePaused,
+		Results: []Result{
+			{
+				Addr:   "addr0",
+				Status: Success,
+			},
+			{
+				Addr:   "addr1",
+				Status: Pending,
+			},
+		},
+	}) {
+		t.Fatal("wrong status", s)
+	}
+
+	task.resume()
+	<-task.done
+
+	s = task.status()
+
+	if s.State != StateDone || s.Results[1].Status != Success {
+		t.Fatal("wrong status", s)
+	}
+}
+
+func TestRunSequentialTaskPauseKill(t *testing.T) {
+	t.Parallel()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	m := NewMockRemoteClient(ctrl)
+
+	task, err := newTask(context.Background(), &TaskConfig{
+		Mode: Sequential,
+		Info: "info",
+		// delay start to pause before the first call
+		Delay: Duration(time.Hour),
+	}, m, []string{"addr0", "addr1"}, log.NewNopLogger())
+	if err != nil {
+		panic(err)
+	}
+
+	if err := task.pause(); err != nil {
+		t.Fatal(err)
+	}
+	task.kill("test")
+	<-task.done
+
+	s := task.status()
+
+	if !reflect.DeepEqual(s, &TaskStatus{
+		ID:         task.ID(),
+		State:      StateDone,
+		KillReason: "test",
+		Results: []Result{
+			{
+				Addr:       "addr0",
+				Status:     Ignored,
+				KillReason: "test",
+			},
+			{
+				Addr:       "addr1",
+				Status:     Ignored,
+				KillReason: "test",
+			},
+		},
+	}) {
+		t.Fatal("wrong status", s)
+	}
+}
+
+func TestRunParallelTaskPause(t *testing.T) {
+	t.Parallel()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	m := NewMockRemoteClient(ctrl)
+	m.EXPECT().Update(gomock.Any(), "addr0", "info").Return(nil)
+
+	task, err := newTask(context.Background(), &TaskConfig{
+		Mode: Parallel,
+		Info: "info",
+	}, m, []string{"addr0"}, log.NewNopLogger())
+	if err != nil {
+		panic(err)
+	}
+	defer func() { <-task.done }()
+
+	if err := task.pause(); err == nil {
+		t.Fatal("expected error")
+	}
+}
+
+func TestRunSequentialTaskCompensation(t *testing.T) {
+	t.Parallel()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	m := NewMockRemoteClient(ctrl)
+	gomock.InOrder(
+		m.EXPECT().Update(gomock.Any(), "addr0", "info").Return(nil),
+		m.EXPECT().Update(gomock.Any(), "addr1", "info").Return(nil),
+		m.EXPECT().Update(gomock.Any(), "addr2", "info").Return(errors.New("boom")),
+		m.EXPECT().Update(gomock.Any(), "addr1", "undo").Return(errors.New("bang")),
+		m.EXPECT().Update(gomock.Any(), "addr0", "undo").Return(nil),
+	)
+
+	task, err := newTask(context.Background(), &TaskConfig{
+		Mode:             Sequential,
+		FailOnError:      true,
+		Info:             "info",
+		CompensationInfo: "undo",
+	}, m, []string{"addr0", "addr1", "addr2", "addr3"}, log.NewNopLogger())
+	if err != nil {
+		panic(err)
+	}
+
+	<-task.done
+
+	s := task.status()
+
+	if !reflect.DeepEqual(s, &TaskStatus{
+		ID:    task.ID(),
+		State: StateDone,
+		Results: []Result{
+			{
+				Addr:   "addr0",
+				Status: RolledBack,
+			},
+			{
+				Addr:   "addr1",
+				Status: RollbackFailed,
+				Msg:    "bang",
+			},
+			{
+				Addr:   "addr2",
+				Status: Failure,
+				Msg:    "boom",
+			},
+			{
+				Addr:   "addr3",
+				Status: Ignored,
+			},
+		},
+	}) {
+		t.Fatal("wrong status", s)
+	}
+}
+
+// afterUpdate calls f after every Update of the inner client returns.
+type afterUpdate struct {
+	RemoteClient
+	f func()
+}
+
+func (c afterUpdate) Update(ctx context.Context, addr, info string) error {
+	err := c.RemoteClient.Update(ctx, addr, info)
+	c.f()
+	return err
+}
+
+func TestRunSequentialTaskKillAfterSuccessNoCompensation(t *testing.T) {
+	t.Parallel()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	m := NewMockRemoteClient(ctrl)
+	m.EXPECT().Update(gomock.Any(), "addr0", "info").Return(nil)
+
+	var (
+		returned = make(chan struct{})
+		killed   = make(chan struct{})
+	)
+	c := afterUpdate{m, func() {
+		close(returned)
+		<-killed
+	}}
+
+	task, err := newTask(context.Background(), &TaskConfig{
+		Mode:             Sequential,
+		Info:             "info",
+		CompensationInfo: "undo",
+	}, c, []string{"addr0"}, log.NewNopLogger())
+	if err != nil {
+		panic(err)
+	}
+
+	<-returned
+	task.kill("test")
+	close(killed)
+	<-task.done
+
+	s := task.status()
+
+	if !reflect.DeepEqual(s, &TaskStatus{
+		ID:         task.ID(),
+		State:      StateDone,
+		KillReason: "test",
+		Results: []Result{
+			{
+				Addr:   "addr0",
+				Status: Success,
+			},
+		},
+	}) {
+		t.Fatal("wrong status", s)
+	}
+}
+
+func TestRunSequentialTaskKillCompensation(t *testing.T) {
+	t.Parallel()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	started := make(chan struct{})
+	m := NewMockRemoteClient(ctrl)
+	gomock.InOrder(
+		m.EXPECT().Update(gomock.Any(), "addr0", "info").Return(nil),
+		m.EXPECT().Update(gomock.Any(), "addr1", "info").Return(context.Canceled).Do(func(ctx context.Context, addr, info string) {
+			close(started)
+			<-ctx.Done()
+		}),
+		m.EXPECT().Update(gomock.Any(), "addr0", "undo").Return(nil).Do(func(ctx context.Context, addr, info string) {
+			if _, ok := ctx.Deadline(); !ok || ctx.Err() != nil {
+				t.Error("compensation context must be bounded and not cancelled")
+			}
+		}),
+	)
+
+	task, err := newTask(context.Background(), &TaskConfig{
+		Mode:             Sequential,
+		Info:             "info",
+		CompensationInfo: "undo",
+	}, m, []string{"addr0", "addr1"}, log.NewNopLogger())
+	if err != nil {
+		panic(err)
+	}
+
+	<-started
+	task.kill("test")
+	<-task.done
+
+	s := task.status()
+
+	if !reflect.DeepEqual(s, &TaskStatus{
+		ID:         task.ID(),
+		State:      StateDone,
+		KillReason: "test",
+		Results: []Result{
+			{
+				Addr:   "addr0",
+				Status: RolledBack,
+			},
+			{
+				Addr:       "addr1",
+				Status:     Killed,
+				KillReason: "test",
+			},
+		},
+	}) {
+		t.Fatal("wrong status", s)
+	}
+}
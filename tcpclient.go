@@ -0,0 +1,115 @@
+package proxy
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"time"
+)
+
+// DefaultTerminator ends lines sent to and received from TCP backends.
+const DefaultTerminator = "\n"
+
+// maxReplyLen is maximal length of TCP backend reply.
+const maxReplyLen = 1024
+
+// dialer is implemented by net.Dialer.
+type dialer interface {
+	DialContext(ctx context.Context, network, addr string) (net.Conn, error)
+}
+
+type tcpClient struct {
+	terminator string
+	dialer     dialer
+}
+
+// NewTCPClient creates instance of TCP line protocol remote client. Update
+// dials addr, writes info followed by terminator and reads a reply line, reply
+// starting with "OK" is a success. Calls of two-phase tasks are prefixed with
+// the phase and a space i.e. "prepare version=2". If terminator is empty
+// DefaultTerminator is used.
+func NewTCPClient(terminator string) RemoteClient {
+	if terminator == "" {
+		terminator = DefaultTerminator
+	}
+	return &tcpClient{
+		terminator: terminator,
+		dialer:     &net.Dialer{},
+	}
+}
+
+func (c *tcpClient) Update(ctx context.Context, addr, info string) error {
+	conn, err := c.dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		if ctx.Err() != nil {
+			return fmt.Errorf("failed to connect: %s", ctx.Err())
+		}
+		return fmt.Errorf("failed to connect: %s", err)
+	}
+	defer conn.Close()
+
+	if d, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(d)
+	}
+
+	// interrupt blocked reads and writes on cancel
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		select {
+		case <-ctx.Done():
+			conn.SetDeadline(time.Unix(1, 0))
+		case <-stop:
+		}
+	}()
+
+	line := info
+	if p := PhaseFromContext(ctx); p != "" {
+		line = string(p) + " " + info
+	}
+
+	if _, err := conn.Write([]byte(line + c.terminator)); err != nil {
+		if ctx.Err() != nil {
+			return fmt.Errorf("failed to send request: %s", ctx.Err())
+		}
+		return fmt.Errorf("failed to send request: %s", err)
+	}
+
+	b, err := c.readLine(bufio.NewReader(conn))
+	if err != nil {
+		if ctx.Err() != nil {
+			return fmt.Errorf("failed to read response: %s", ctx.Err())
+		}
+		return fmt.Errorf("failed to read response: %s", err)
+	}
+
+	if !bytes.HasPrefix(b, []byte("OK")) {
+		return fmt.Errorf("remote failure: %s", b)
+	}
+
+	return nil
+}
+
+// readLine reads until terminator or EOF, terminator is not returned.
+func (c *tcpClient) readLine(r *bufio.Reader) ([]byte, error) {
+	var buf []byte
+	for {
+		v, err := r.ReadByte()
+		if err != nil {
+			if len(buf) > 0 && err == io.EOF {
+				return buf, nil
+			}
+			return nil, err
+		}
+		buf = append(buf, v)
+		if bytes.HasSuffix(buf, []byte(c.terminator)) {
+			return buf[:len(buf)-len(c.terminator)], nil
+		}
+		if len(buf) > maxReplyLen {
+			return nil, fmt.Errorf("reply longer than %d bytes", maxReplyLen)
+		}
+	}
+}
@@ -0,0 +1,211 @@
+package proxy
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+)
+
+// serveTCP starts TCP server that reads a line and replies with reply.
+func serveTCP(t *testing.T, terminator, reply string) net.Listener {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				line, err := bufio.NewReader(conn).ReadString(terminator[len(terminator)-1])
+				if err != nil {
+					return
+				}
+				if !strings.HasSuffix(line, terminator) {
+					t.Error("missing terminator", line)
+				}
+				conn.Write([]byte(reply))
+			}()
+		}
+	}()
+
+	return l
+}
+
+func TestTCPClientOK(t *testing.T) {
+	t.Parallel()
+
+	l := serveTCP(t, "\n", "OK\n")
+	defer l.Close()
+
+	c := NewTCPClient("")
+
+	err := c.Update(context.Background(), l.Addr().String(), "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestTCPClientTerminator(t *testing.T) {
+	t.Parallel()
+
+	l := serveTCP(t, "\r\n", "OK done\r\n")
+	defer l.Close()
+
+	c := NewTCPClient("\r\n")
+
+	err := c.Update(context.Background(), l.Addr().String(), "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestTCPClientError(t *testing.T) {
+	t.Parallel()
+
+	l := serveTCP(t, "\n", "ERR no such account\n")
+	defer l.Close()
+
+	c := NewTCPClient("")
+
+	err := c.Update(context.Background(), l.Addr().String(), "test")
+	if err == nil || !strings.Contains(err.Error(), "ERR no such account") {
+		t.Fatal("expected remote failure, got", err)
+	}
+}
+
+func TestTCPClientPhase(t *testing.T) {
+	t.Parallel()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	lines := make(chan string, 1)
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		line, _ := bufio.NewReader(conn).ReadString('\n')
+		lines <- line
+		conn.Write([]byte("OK\n"))
+	}()
+
+	c := NewTCPClient("")
+
+	err = c.Update(WithPhase(context.Background(), PhasePrepare), l.Addr().String(), "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if line := <-lines; line != "prepare test\n" {
+		t.Fatal("wrong line", line)
+	}
+}
+
+func TestTCPClientCancel(t *testing.T) {
+	t.Parallel()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	// accept but never reply
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+
+	c := NewTCPClient("")
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		cancel()
+	}()
+
+	err = c.Update(ctx, l.Addr().String(), "test")
+	if err == nil || !strings.Contains(err.Error(), context.Canceled.Error()) {
+		t.Fatal("expected canceled, got", err)
+	}
+}
+
+func TestRemoteClientBackendClient(t *testing.T) {
+	t.Parallel()
+
+	l := serveTCP(t, "\n", "OK\n")
+	defer l.Close()
+
+	addr := l.Addr().String()
+	c := NewRemoteClient(WithBackendClient(addr, NewTCPClient("")))
+
+	err := c.Update(context.Background(), addr, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// blockingDialer blocks until ctx is done and fails like net.Dialer does.
+type blockingDialer struct {
+	started chan struct{}
+}
+
+func (d blockingDialer) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	close(d.started)
+	<-ctx.Done()
+	return nil, &net.OpError{Op: "dial", Net: network, Err: errors.New("operation was canceled")}
+}
+
+func TestTCPClientKillDial(t *testing.T) {
+	t.Parallel()
+
+	d := blockingDialer{started: make(chan struct{})}
+	c := &tcpClient{
+		terminator: DefaultTerminator,
+		dialer:     d,
+	}
+
+	task, err := newTask(context.Background(), &TaskConfig{
+		Mode: Parallel,
+		Info: "info",
+	}, c, []string{"addr0"}, log.NewNopLogger())
+	if err != nil {
+		panic(err)
+	}
+
+	<-d.started
+	task.kill("test")
+	<-task.done
+
+	s := task.status()
+
+	if !reflect.DeepEqual(s.Results, []Result{
+		{
+			Addr:       "addr0",
+			Status:     Killed,
+			KillReason: "test",
+		},
+	}) {
+		t.Fatal("wrong status", s)
+	}
+}
@@ -0,0 +1,49 @@
+package trace
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+)
+
+// Exporter reports finished spans, implementations must be thread safe.
+type Exporter interface {
+	Export(s *SpanData) error
+}
+
+var (
+	global   Exporter
+	globalMu sync.RWMutex
+)
+
+// SetExporter sets exporter used by all spans, nil disables exporting.
+func SetExporter(e Exporter) {
+	globalMu.Lock()
+	global = e
+	globalMu.Unlock()
+}
+
+func exporter() Exporter {
+	globalMu.RLock()
+	defer globalMu.RUnlock()
+	return global
+}
+
+type jsonExporter struct {
+	enc *json.Encoder
+	mu  sync.Mutex
+}
+
+// NewJSONExporter creates exporter that writes spans to w as JSON, one span
+// per line.
+func NewJSONExporter(w io.Writer) Exporter {
+	return &jsonExporter{
+		enc: json.NewEncoder(w),
+	}
+}
+
+func (e *jsonExporter) Export(s *SpanData) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.enc.Encode(s)
+}
@@ -0,0 +1,258 @@
+// Package trace provides minimal distributed tracing compatible with W3C
+// Trace Context propagation. Spans are reported to an Exporter set with
+// SetExporter, if no exporter is set spans are only propagated.
+package trace
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TraceID identifies a trace.
+type TraceID [16]byte
+
+func (t TraceID) String() string {
+	return hex.EncodeToString(t[:])
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (t TraceID) MarshalText() ([]byte, error) {
+	return []byte(t.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (t *TraceID) UnmarshalText(b []byte) error {
+	return decodeHex(t[:], string(b))
+}
+
+// SpanID identifies a span.
+type SpanID [8]byte
+
+func (s SpanID) String() string {
+	return hex.EncodeToString(s[:])
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (s SpanID) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (s *SpanID) UnmarshalText(b []byte) error {
+	return decodeHex(s[:], string(b))
+}
+
+// SpanContext is the part of a span that is propagated across process
+// boundaries.
+type SpanContext struct {
+	TraceID TraceID
+	SpanID  SpanID
+	Sampled bool
+}
+
+// IsValid returns true if trace and span identifiers are set.
+func (sc SpanContext) IsValid() bool {
+	return sc.TraceID != TraceID{} && sc.SpanID != SpanID{}
+}
+
+// SpanData is a finished span as reported to Exporter.
+type SpanData struct {
+	TraceID    TraceID                `json:"trace_id"`
+	SpanID     SpanID                 `json:"span_id"`
+	ParentID   *SpanID                `json:"parent_id,omitempty"`
+	Name       string                 `json:"name"`
+	Start      time.Time              `json:"start"`
+	End        time.Time              `json:"end"`
+	Attributes map[string]interface{} `json:"attributes,omitempty"`
+	Error      string                 `json:"error,omitempty"`
+}
+
+// Span represents a single operation within a trace, it is safe for
+// concurrent use.
+type Span struct {
+	sc   SpanContext
+	data SpanData
+	// mu protects data
+	mu    sync.Mutex
+	ended bool
+}
+
+// SpanContext returns span context of the span.
+func (s *Span) SpanContext() SpanContext {
+	return s.sc
+}
+
+// SetAttributes sets span attributes given as key value pairs.
+func (s *Span) SetAttributes(keyvals ...interface{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.ended {
+		return
+	}
+	if s.data.Attributes == nil {
+		s.data.Attributes = make(map[string]interface{})
+	}
+	for i := 0; i+1 < len(keyvals); i += 2 {
+		s.data.Attributes[fmt.Sprint(keyvals[i])] = keyvals[i+1]
+	}
+}
+
+// SetError marks span as failed.
+func (s *Span) SetError(err error) {
+	if err == nil {
+		return
+	}
+
+	s.mu.Lock()
+	if !s.ended {
+		s.data.Error = err.Error()
+	}
+	s.mu.Unlock()
+}
+
+// End finishes span and reports it to exporter, calling End more than once
+// has no effect.
+func (s *Span) End() {
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+	s.ended = true
+	s.data.End = time.Now()
+	d := s.data
+	s.mu.Unlock()
+
+	if !s.sc.Sampled {
+		return
+	}
+	if e := exporter(); e != nil {
+		e.Export(&d)
+	}
+}
+
+type spanKey struct{}
+
+type remoteKey struct{}
+
+// Start creates a new span, child of span in ctx, if ctx contains no span
+// but has a remote span context the span continues remote trace otherwise
+// a new trace is started.
+func Start(ctx context.Context, name string) (context.Context, *Span) {
+	var parent SpanContext
+	if p := FromContext(ctx); p != nil {
+		parent = p.sc
+	} else if sc, ok := ctx.Value(remoteKey{}).(SpanContext); ok {
+		parent = sc
+	}
+
+	s := &Span{}
+	if parent.IsValid() {
+		s.sc.TraceID = parent.TraceID
+		s.sc.Sampled = parent.Sampled
+		id := parent.SpanID
+		s.data.ParentID = &id
+	} else {
+		rand.Read(s.sc.TraceID[:])
+		s.sc.Sampled = true
+	}
+	rand.Read(s.sc.SpanID[:])
+
+	s.data.TraceID = s.sc.TraceID
+	s.data.SpanID = s.sc.SpanID
+	s.data.Name = name
+	s.data.Start = time.Now()
+
+	return context.WithValue(ctx, spanKey{}, s), s
+}
+
+// FromContext returns span stored in ctx or nil.
+func FromContext(ctx context.Context) *Span {
+	s, _ := ctx.Value(spanKey{}).(*Span)
+	return s
+}
+
+// WithRemoteSpanContext returns context with span context received from
+// a remote process, spans started from the context become its children.
+func WithRemoteSpanContext(ctx context.Context, sc SpanContext) context.Context {
+	return context.WithValue(ctx, remoteKey{}, sc)
+}
+
+// TraceparentHeader is W3C Trace Context header name.
+const TraceparentHeader = "traceparent"
+
+// Inject sets traceparent header based on span in ctx.
+func Inject(ctx context.Context, h http.Header) {
+	s := FromContext(ctx)
+	if s == nil {
+		return
+	}
+	h.Set(TraceparentHeader, FormatTraceparent(s.sc))
+}
+
+// Extract reads span context from traceparent header.
+func Extract(h http.Header) (SpanContext, bool) {
+	v := h.Get(TraceparentHeader)
+	if v == "" {
+		return SpanContext{}, false
+	}
+	sc, err := ParseTraceparent(v)
+	if err != nil {
+		return SpanContext{}, false
+	}
+	return sc, true
+}
+
+// FormatTraceparent returns traceparent header value.
+func FormatTraceparent(sc SpanContext) string {
+	flags := "00"
+	if sc.Sampled {
+		flags = "01"
+	}
+	return fmt.Sprintf("00-%s-%s-%s", sc.TraceID, sc.SpanID, flags)
+}
+
+// ParseTraceparent parses traceparent header value.
+func ParseTraceparent(v string) (SpanContext, error) {
+	var sc SpanContext
+
+	parts := strings.Split(strings.TrimSpace(v), "-")
+	if len(parts) < 4 {
+		return sc, fmt.Errorf("invalid traceparent %q", v)
+	}
+	if len(parts[0]) != 2 || parts[0] == "ff" || (parts[0] == "00" && len(parts) != 4) {
+		return sc, fmt.Errorf("unsupported traceparent version %q", parts[0])
+	}
+	if err := decodeHex(sc.TraceID[:], parts[1]); err != nil {
+		return sc, fmt.Errorf("invalid trace id: %s", err)
+	}
+	if err := decodeHex(sc.SpanID[:], parts[2]); err != nil {
+		return sc, fmt.Errorf("invalid span id: %s", err)
+	}
+	var flags [1]byte
+	if err := decodeHex(flags[:], parts[3]); err != nil {
+		return sc, fmt.Errorf("invalid flags: %s", err)
+	}
+	sc.Sampled = flags[0]&0x01 == 0x01
+
+	if !sc.IsValid() {
+		return sc, fmt.Errorf("invalid traceparent %q", v)
+	}
+
+	return sc, nil
+}
+
+func decodeHex(dst []byte, s string) error {
+	if len(s) != hex.EncodedLen(len(dst)) || strings.ToLower(s) != s {
+		return fmt.Errorf("malformed %q", s)
+	}
+	_, err := hex.Decode(dst, []byte(s))
+	return err
+}
@@ -0,0 +1,87 @@
+package trace
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+)
+
+func TestParseTraceparent(t *testing.T) {
+	t.Parallel()
+
+	v := "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	sc, err := ParseTraceparent(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sc.TraceID.String() != "4bf92f3577b34da6a3ce929d0e0e4736" || sc.SpanID.String() != "00f067aa0ba902b7" || !sc.Sampled {
+		t.Fatal("wrong span context", sc)
+	}
+	if FormatTraceparent(sc) != v {
+		t.Fatal("wrong format", FormatTraceparent(sc))
+	}
+}
+
+func TestParseTraceparentInvalid(t *testing.T) {
+	t.Parallel()
+
+	for _, v := range []string{
+		"",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7",
+		"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01",
+		"00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra",
+	} {
+		if _, err := ParseTraceparent(v); err == nil {
+			t.Error("expected error", v)
+		}
+	}
+}
+
+func TestStartContinuesRemoteTrace(t *testing.T) {
+	var buf bytes.Buffer
+	SetExporter(NewJSONExporter(&buf))
+	defer SetExporter(nil)
+
+	h := http.Header{}
+	h.Set(TraceparentHeader, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	sc, ok := Extract(h)
+	if !ok {
+		t.Fatal("extract failed")
+	}
+
+	ctx, parent := Start(WithRemoteSpanContext(context.Background(), sc), "parent")
+	ctx, child := Start(ctx, "child")
+	child.SetAttributes("key", "value")
+	child.End()
+	parent.End()
+
+	out := http.Header{}
+	Inject(ctx, out)
+	if out.Get(TraceparentHeader) != FormatTraceparent(child.SpanContext()) {
+		t.Fatal("wrong traceparent", out)
+	}
+
+	d := json.NewDecoder(&buf)
+	var c, p SpanData
+	if err := d.Decode(&c); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Decode(&p); err != nil {
+		t.Fatal(err)
+	}
+
+	if c.Name != "child" || c.Attributes["key"] != "value" {
+		t.Fatal("wrong child", c)
+	}
+	if p.TraceID != sc.TraceID || *p.ParentID != sc.SpanID {
+		t.Fatal("wrong parent", p)
+	}
+	if c.TraceID != sc.TraceID || *c.ParentID != p.SpanID {
+		t.Fatal("wrong child", c)
+	}
+}
@@ -0,0 +1,153 @@
+package proxy
+
+import (
+	"context"
+	"sync"
+
+	"github.com/mmatczuk/proxy/log"
+	"github.com/mmatczuk/proxy/trace"
+)
+
+// Phase specifies phase of a two-phase task.
+type Phase string
+
+// Phase values.
+const (
+	PhasePrepare Phase = "prepare"
+	PhaseCommit  Phase = "commit"
+	PhaseAbort   Phase = "abort"
+)
+
+// PhaseHeader is the HTTP header carrying phase of a two-phase task.
+const PhaseHeader = "X-Proxy-Phase"
+
+type phaseKey struct{}
+
+// WithPhase returns context with phase of a two-phase task.
+func WithPhase(ctx context.Context, p Phase) context.Context {
+	return context.WithValue(ctx, phaseKey{}, p)
+}
+
+// PhaseFromContext returns phase stored in ctx or empty string if the call
+// is not a part of two-phase task.
+func PhaseFromContext(ctx context.Context) Phase {
+	p, _ := ctx.Value(phaseKey{}).(Phase)
+	return p
+}
+
+// startPhase works like start for calls of a two-phase task, calls after
+// prepare start only if the previous phase succeeded.
+func (r *result) startPhase(p Phase, cancel context.CancelFunc) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if p == PhasePrepare && r.Status != Pending {
+		return false
+	}
+	if p != PhasePrepare && r.Status != Success {
+		return false
+	}
+	r.Status = Running
+	r.Phase = p
+	r.Msg = ""
+	r.cancel = cancel
+	return true
+}
+
+// runTwoPhase sends prepare to all addresses in parallel, if enough of them
+// succeed commit is sent to the prepared addresses, otherwise they are sent
+// abort. Abort is sent even if task is killed.
+func (t *task) runTwoPhase(ctx context.Context, config *TaskConfig) {
+	t.runPhase(ctx, config, PhasePrepare, t.results)
+
+	var prepared []*result
+	for _, r := range t.results {
+		if r.status() == Success {
+			prepared = append(prepared, r)
+		}
+	}
+
+	required := config.Required.count(len(t.results))
+	if !t.killed() && len(prepared) >= required {
+		t.runPhase(ctx, config, PhaseCommit, prepared)
+		return
+	}
+
+	log.Info(t.logger).Log(
+		"msg", "aborting task",
+		"prepared", len(prepared),
+		"required", required,
+	)
+	ctx, cancel := rollbackContext(ctx)
+	defer cancel()
+	t.abort(ctx, config, prepared)
+}
+
+// runPhase calls addresses of results concurrently.
+func (t *task) runPhase(ctx context.Context, config *TaskConfig, p Phase, results []*result) {
+	info := config.Info
+	if p == PhasePrepare {
+		info = config.PrepareInfo
+	}
+
+	var wg sync.WaitGroup
+	for _, r := range results {
+		r := r
+		wg.Add(1)
+		go func() {
+			ctx, cancel := context.WithCancel(WithPhase(ctx, p))
+			defer cancel()
+			if r.startPhase(p, cancel) {
+				t.update(ctx, config, r, info)
+			}
+			wg.Done()
+		}()
+	}
+	wg.Wait()
+}
+
+// abort sends abort to prepared addresses concurrently, results are marked
+// as rolled back.
+func (t *task) abort(ctx context.Context, config *TaskConfig, prepared []*result) {
+	ctx, span := trace.Start(WithPhase(ctx, PhaseAbort), "abort")
+	defer span.End()
+
+	var wg sync.WaitGroup
+	for _, r := range prepared {
+		r := r
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			r.mu.Lock()
+			r.Phase = PhaseAbort
+			r.mu.Unlock()
+
+			err := t.client.Update(ctx, r.Addr, config.AbortInfo)
+			r.rollback(err)
+			if err != nil {
+				remoteCalls.WithLabelValues(r.Addr, RollbackFailed).Inc()
+				log.Error(t.logger).Log(
+					"msg", "abort failure",
+					"addr", r.Addr,
+					"err", err,
+				)
+				return
+			}
+			remoteCalls.WithLabelValues(r.Addr, RolledBack).Inc()
+		}()
+	}
+	wg.Wait()
+}
+
+// planTwoPhase returns steps of a two-phase task assuming all addresses are
+// prepared.
+func planTwoPhase(config *TaskConfig, addrs []string) []PlanStep {
+	prepare := PlanStep{}
+	commit := PlanStep{}
+	for _, addr := range addrs {
+		prepare.Calls = append(prepare.Calls, PlanCall{Addr: addr, Info: config.PrepareInfo, Phase: PhasePrepare})
+		commit.Calls = append(commit.Calls, PlanCall{Addr: addr, Info: config.Info, Phase: PhaseCommit})
+	}
+	return []PlanStep{prepare, commit}
+}
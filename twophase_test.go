@@ -0,0 +1,225 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/go-kit/kit/log"
+	"github.com/golang/mock/gomock"
+)
+
+// phaseIs matches context carrying the phase.
+type phaseIs Phase
+
+func (p phaseIs) Matches(x interface{}) bool {
+	ctx, ok := x.(context.Context)
+	return ok && PhaseFromContext(ctx) == Phase(p)
+}
+
+func (p phaseIs) String() string {
+	return "has phase " + string(p)
+}
+
+func TestRunTwoPhaseTaskCommit(t *testing.T) {
+	t.Parallel()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	m := NewMockRemoteClient(ctrl)
+	for _, addr := range []string{"addr0", "addr1"} {
+		gomock.InOrder(
+			m.EXPECT().Update(phaseIs(PhasePrepare), addr, "prepare").Return(nil),
+			m.EXPECT().Update(phaseIs(PhaseCommit), addr, "info").Return(nil),
+		)
+	}
+
+	task, err := newTask(context.Background(), &TaskConfig{
+		Mode:        TwoPhase,
+		Info:        "info",
+		PrepareInfo: "prepare",
+		AbortInfo:   "abort",
+	}, m, []string{"addr0", "addr1"}, log.NewNopLogger())
+	if err != nil {
+		panic(err)
+	}
+
+	<-task.done
+
+	s := task.status()
+
+	if !reflect.DeepEqual(s, &TaskStatus{
+		ID:    task.ID(),
+		State: StateDone,
+		Results: []Result{
+			{
+				Addr:   "addr0",
+				Status: Success,
+				Phase:  PhaseCommit,
+			},
+			{
+				Addr:   "addr1",
+				Status: Success,
+				Phase:  PhaseCommit,
+			},
+		},
+	}) {
+		t.Fatal("wrong status", s)
+	}
+}
+
+func TestRunTwoPhaseTaskAbort(t *testing.T) {
+	t.Parallel()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	m := NewMockRemoteClient(ctrl)
+	gomock.InOrder(
+		m.EXPECT().Update(phaseIs(PhasePrepare), "addr0", "prepare").Return(nil),
+		m.EXPECT().Update(phaseIs(PhaseAbort), "addr0", "abort").Return(nil),
+	)
+	m.EXPECT().Update(phaseIs(PhasePrepare), "addr1", "prepare").Return(errors.New("boom"))
+
+	task, err := newTask(context.Background(), &TaskConfig{
+		Mode:        TwoPhase,
+		Info:        "info",
+		PrepareInfo: "prepare",
+		AbortInfo:   "abort",
+	}, m, []string{"addr0", "addr1"}, log.NewNopLogger())
+	if err != nil {
+		panic(err)
+	}
+
+	<-task.done
+
+	s := task.status()
+
+	if !reflect.DeepEqual(s, &TaskStatus{
+		ID:    task.ID(),
+		State: StateDone,
+		Results: []Result{
+			{
+				Addr:   "addr0",
+				Status: RolledBack,
+				Phase:  PhaseAbort,
+			},
+			{
+				Addr:   "addr1",
+				Status: Failure,
+				Msg:    "boom",
+				Phase:  PhasePrepare,
+			},
+		},
+	}) {
+		t.Fatal("wrong status", s)
+	}
+}
+
+func TestRunTwoPhaseTaskRequired(t *testing.T) {
+	t.Parallel()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	m := NewMockRemoteClient(ctrl)
+	gomock.InOrder(
+		m.EXPECT().Update(phaseIs(PhasePrepare), "addr0", "prepare").Return(nil),
+		m.EXPECT().Update(phaseIs(PhaseCommit), "addr0", "info").Return(nil),
+	)
+	m.EXPECT().Update(phaseIs(PhasePrepare), "addr1", "prepare").Return(errors.New("boom"))
+
+	task, err := newTask(context.Background(), &TaskConfig{
+		Mode:        TwoPhase,
+		Info:        "info",
+		PrepareInfo: "prepare",
+		AbortInfo:   "abort",
+		Required:    0.5,
+	}, m, []string{"addr0", "addr1"}, log.NewNopLogger())
+	if err != nil {
+		panic(err)
+	}
+
+	<-task.done
+
+	s := task.status()
+
+	if s.Results[0].Phase != PhaseCommit || s.Results[0].Status != Success || s.Results[1].Status != Failure {
+		t.Fatal("wrong status", s)
+	}
+}
+
+func TestValidateConfigTwoPhase(t *testing.T) {
+	t.Parallel()
+
+	table := []struct {
+		Config TaskConfig
+		Valid  bool
+	}{
+		{TaskConfig{Mode: TwoPhase, PrepareInfo: "prepare", AbortInfo: "abort", Required: 0.5}, true},
+		{TaskConfig{Mode: TwoPhase, PrepareInfo: "prepare", AbortInfo: "abort", Required: 2}, true},
+		{TaskConfig{Mode: TwoPhase, PrepareInfo: "prepare", AbortInfo: "abort", Required: 1.5}, false},
+		{TaskConfig{Mode: TwoPhase, PrepareInfo: "prepare", AbortInfo: "abort", Required: -1}, false},
+		{TaskConfig{Mode: TwoPhase, PrepareInfo: "prepare", AbortInfo: "abort", CompensationInfo: "undo"}, false},
+		{TaskConfig{Mode: TwoPhase, PrepareInfo: "prepare"}, false},
+		{TaskConfig{Mode: TwoPhase, AbortInfo: "abort"}, false},
+		{TaskConfig{Mode: Sequential, Required: 1}, false},
+		{TaskConfig{Mode: Parallel, PrepareInfo: "prepare"}, false},
+	}
+
+	for i, test := range table {
+		err := validateConfig(&test.Config)
+		if (err == nil) != test.Valid {
+			t.Fatal(i, err)
+		}
+	}
+}
+
+func TestServiceRetryTwoPhaseTask(t *testing.T) {
+	t.Parallel()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	m := NewMockRemoteClient(ctrl)
+	for _, addr := range []string{"addr0", "addr1"} {
+		gomock.InOrder(
+			m.EXPECT().Update(phaseIs(PhasePrepare), addr, "prepare").Return(nil),
+			m.EXPECT().Update(phaseIs(PhaseCommit), addr, "info").Return(nil),
+		)
+	}
+	gomock.InOrder(
+		m.EXPECT().Update(phaseIs(PhasePrepare), "addr2", "prepare").Return(errors.New("boom")),
+		m.EXPECT().Update(phaseIs(PhasePrepare), "addr2", "prepare").Return(nil),
+		m.EXPECT().Update(phaseIs(PhaseCommit), "addr2", "info").Return(nil),
+	)
+
+	s := NewService(m, []string{"addr0", "addr1", "addr2"}, log.NewNopLogger()).(*service)
+	ctx := context.Background()
+
+	id, err := s.CreateTask(ctx, &TaskConfig{Mode: TwoPhase, Info: "info", PrepareInfo: "prepare", AbortInfo: "abort", Required: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-s.tasks[id].done
+
+	child, err := s.RetryTask(ctx, id, RetryOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-s.tasks[child].done
+
+	st, _ := s.TaskStatus(ctx, child)
+	if !reflect.DeepEqual(st, &TaskStatus{
+		ID:       child,
+		State:    StateDone,
+		ParentID: id,
+		Results: []Result{
+			{
+				Addr:   "addr2",
+				Status: Success,
+				Phase:  PhaseCommit,
+			},
+		},
+	}) {
+		t.Fatal("wrong status", st)
+	}
+}